@@ -3,9 +3,9 @@ package polygonio
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"net/http"
 	"sort"
 	"strconv"
@@ -17,14 +17,22 @@ import (
 type AggregatesRequest struct {
 	Ticker     string
 	Multiplier int64
-	Timespan   string    //minute,hour,day,week,month,quarter
+	Timespan   Timespan  //minute,hour,day,week,month,quarter,year
 	From       time.Time //pre-market trading (4am est)
 	To         time.Time //end of market (4pm est)
 	Unadjusted bool
 }
 
+//Sessions sets From and To to the trading days of the sessions from and to belong to, so a range given in
+//exchange time does not start on a weekend or end on the day before an early morning bar
+func (ar AggregatesRequest) Sessions(from time.Time, to time.Time) AggregatesRequest {
+	ar.From, ar.To = FromDate(from), FromDate(to)
+	return ar
+}
+
+//TimespanDuration is an average for day and longer, see Timespan.Add
 func (ar AggregatesRequest) TimespanDuration() time.Duration {
-	return TimespanAsDuration(ar.Timespan) * time.Duration(ar.Multiplier)
+	return ar.Timespan.Duration() * time.Duration(ar.Multiplier)
 }
 
 /*
@@ -58,13 +66,17 @@ type AggregatesResponse struct {
 	Low         decimal.Decimal `json:"l"`
 	UnixMiliSec int64           `json:"t"`
 	N           int64           `json:"n"`
+	//volume weighted average price, zero when polygon sent none
+	VWAP decimal.Decimal `json:"vw"`
 
-	//set by Aggregates()
+	//set by Aggregates(), ImpliedEnd follows the calendar when timespan is set
+	timespan         Timespan
+	multiplier       int64
 	timespanDuration time.Duration
 }
 
 func (ag AggregatesResponse) String() string {
-	return fmt.Sprintf("%s-%s (%s) o:%s c:%s h:%s l:%s v:%s",
+	return fmt.Sprintf("%s-%s (%s) o:%s c:%s h:%s l:%s v:%s vw:%s",
 		ag.UnixMiliSecInTime().Format(StringFormat),
 		ag.ImpliedEnd().Format(StringFormat),
 		ag.timespanDuration,
@@ -73,41 +85,63 @@ func (ag AggregatesResponse) String() string {
 		ag.High.String(),
 		ag.Low.String(),
 		ag.Volume.String(),
+		ag.VWAP.String(),
 	)
 }
 
+//Merge combines bars in time order into one spanning all of them, the zero value for none. See Resample for aligned buckets
 func Merge(in []AggregatesResponse) AggregatesResponse {
 
 	if len(in) == 0 {
-		panic("expected len > 0")
+		return AggregatesResponse{}
 	}
 
 	if len(in) == 1 {
 		return in[0]
 	}
 
-	if len(in) != 2 {
-		panic("expected len == 2")
+	out := in[0]
+	for _, b := range in[1:] {
+		out.Volume = out.Volume.Add(b.Volume)
+		out.Close = b.Close
+		if b.High.GreaterThan(out.High) {
+			out.High = b.High
+		}
+		if b.Low.LessThan(out.Low) {
+			out.Low = b.Low
+		}
+		out.N += b.N
 	}
 
-	a := in[0]
-	b := in[1]
-
-	out := a
-	out.Volume = a.Volume.Add(b.Volume)
-	out.Close = b.Close
-	if b.High.GreaterThan(out.High) {
-		out.High = b.High
+	//a bar without vw says nothing about the price its volume traded at, only bars with one are weighted
+	weighted, volume, vwaps, n := decimal.Zero, decimal.Zero, decimal.Zero, int64(0)
+	for _, b := range in {
+		if b.VWAP.IsZero() {
+			continue
+		}
+		weighted = weighted.Add(b.VWAP.Mul(b.Volume))
+		volume = volume.Add(b.Volume)
+		vwaps = vwaps.Add(b.VWAP)
+		n++
 	}
-	if b.Low.LessThan(out.Low) {
-		out.Low = b.Low
+	//weighted by volume, without volume no price says more than the others
+	switch {
+	case n == 0:
+		out.VWAP = decimal.Zero
+	case volume.IsZero():
+		out.VWAP = vwaps.Div(decimal.NewFromInt(n))
+	default:
+		out.VWAP = weighted.Div(volume)
 	}
-	out.N += b.N
-	out.timespanDuration = b.ImpliedEnd().Sub(a.UnixMiliSecInTime())
+	out.timespan = ""
+	out.timespanDuration = in[len(in)-1].ImpliedEnd().Sub(in[0].UnixMiliSecInTime())
 	return out
 }
 
 func (ar AggregatesResponse) ImpliedEnd() time.Time {
+	if ar.timespan != "" {
+		return ar.timespan.Add(ar.UnixMiliSecInTime(), ar.multiplier)
+	}
 	return ar.UnixMiliSecInTime().Add(ar.timespanDuration)
 }
 
@@ -126,7 +160,86 @@ func (ag AggregatesResponse) UnixMiliSecInTime() time.Time {
 }
 
 type AggregatesResponseContainer struct {
+	Ticker       string `json:"ticker"`
+	Status       string `json:"status"`
+	QueryCount   int64  `json:"queryCount"`
+	ResultsCount int64  `json:"resultsCount"`
+	Adjusted     bool   `json:"adjusted"`
+	RequestID    string `json:"request_id"`
+	//nil when the response had no results key
 	Results []AggregatesResponse `json:"results"`
+	//the response reached AggregatesMaxResults and may be missing later bars
+	Truncated bool `json:"-"`
+}
+
+//ErrNoResults matches the *AggregatesError of a response without a results key, polygon leaves it out when nothing traded
+var ErrNoResults = errors.New("no results")
+
+//AggregatesError is returned for a 200 response whose status is not OK or that has no results
+type AggregatesError struct {
+	Ticker    string
+	Status    string
+	RequestID string
+	//the results key was missing
+	NoResults bool
+}
+
+func (ae *AggregatesError) Error() string {
+	out := "Aggregates " + ae.Ticker + ":"
+	if ae.Status != "" {
+		out += " status " + ae.Status
+	}
+	if ae.NoResults {
+		out += " no results"
+	}
+	if ae.RequestID != "" {
+		out += " (request_id " + ae.RequestID + ")"
+	}
+	return out
+}
+
+func (ae *AggregatesError) Is(target error) bool {
+	return target == ErrNoResults && ae.NoResults
+}
+
+//aggregatesStatusOK is true for the statuses that come with results, an empty status is accepted for responses
+//that do not carry one and DELAYED is what plans without real time data get
+func aggregatesStatusOK(status string) bool {
+	return status == "" || status == "OK" || status == "DELAYED"
+}
+
+//Err checks the envelope
+func (arc AggregatesResponseContainer) Err() error {
+	if aggregatesStatusOK(arc.Status) && arc.Results != nil {
+		return nil
+	}
+	return &AggregatesError{Ticker: arc.Ticker, Status: arc.Status, RequestID: arc.RequestID, NoResults: arc.Results == nil}
+}
+
+//aggregatesEnvelope keeps error statuses out of the cache, a cached error would be served until it expires and
+//for a completed session it never does. A missing results key is cached, nothing traded in the range
+func aggregatesEnvelope(body []byte) error {
+	envelope := struct {
+		Ticker    string `json:"ticker"`
+		Status    string `json:"status"`
+		RequestID string `json:"request_id"`
+	}{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return err
+	}
+	if !aggregatesStatusOK(envelope.Status) {
+		return &AggregatesError{Ticker: envelope.Ticker, Status: envelope.Status, RequestID: envelope.RequestID}
+	}
+	return nil
+}
+
+//ApplyRequest sets what the response does not carry itself, Aggregates() calls it on every response
+func (arc *AggregatesResponseContainer) ApplyRequest(request AggregatesRequest) {
+	for i := range arc.Results {
+		arc.Results[i].timespan = request.Timespan
+		arc.Results[i].multiplier = request.Multiplier
+		arc.Results[i].timespanDuration = request.TimespanDuration()
+	}
 }
 
 func (arc AggregatesResponseContainer) ClosestAggregate(t time.Time) ([]AggregatesResponse, bool) {
@@ -159,6 +272,8 @@ func (pc PolygonioClient) AggregatesRequest(ctx context.Context, request Aggrega
 	base.Path = fmt.Sprintf("/v2/aggs/ticker/%s/range/%d/%s/%s/%s", request.Ticker, request.Multiplier, request.Timespan, DateFormat(request.From), DateFormat(request.To))
 	q := base.Query()
 	q.Add("unadjusted", strconv.FormatBool(request.Unadjusted))
+	//polygon's default is far below what a chunk of AggregatesRange can hold
+	q.Add("limit", strconv.Itoa(AggregatesMaxResults))
 	base.RawQuery = q.Encode()
 	req, err := http.NewRequestWithContext(ctx, "GET", base.String(), nil)
 	if err != nil {
@@ -168,7 +283,10 @@ func (pc PolygonioClient) AggregatesRequest(ctx context.Context, request Aggrega
 }
 
 func (pc PolygonioClient) Aggregates(ctx context.Context, request AggregatesRequest) (*AggregatesResponseContainer, error) {
-	resp, err := DoCache(pc.HTTPClient, pc.AggregatesRequest(ctx, request), true, pc.Cacher)
+	if err := request.Timespan.validate(); err != nil {
+		return nil, err
+	}
+	resp, err := pc.doCache(pc.AggregatesRequest(ctx, request), AggregatesFreshness(request, pc.now(), pc.liveTTL()), aggregatesEnvelope)
 	if err != nil {
 		return nil, err
 	}
@@ -182,51 +300,13 @@ func (pc PolygonioClient) Aggregates(ctx context.Context, request AggregatesRequ
 		if err := json.Unmarshal(bytes, out); err != nil {
 			return nil, err
 		}
-		for i := range out.Results {
-			out.Results[i].timespanDuration = request.TimespanDuration()
-		}
-		return out, nil
-	}
-	return nil, StatusError(resp.StatusCode)
-}
-
-var LimitExceededError = fmt.Errorf("Search limit exceeded")
-var SearchReturnedNoResults = fmt.Errorf("Search returned no results")
-
-//search API until we have a result less than search and we have a result that contains the search date (or two results on either side of it)
-func (pc PolygonioClient) AggregatesSearch(ctx context.Context, request AggregatesRequest, search time.Time) ([]AggregatesResponse, error) {
-	request.From = FromDate(search)
-	request.To = ToDate(search)
-	count := 0
-
-	for {
-		results, err := pc.Aggregates(ctx, request)
-		if err != nil {
+		if err := out.Err(); err != nil {
 			return nil, err
 		}
-
-		//fmt.Println(request.From.Format("2006-01-02 3:04:05 PM MST"), request.To.Format("2006-01-02 3:04:05 PM MST"))
-		closest, found := results.ClosestAggregate(search)
-		if found {
-			return closest, nil
-		}
-
-		//randomly extend on one direction (this is kind of stretch)
-		if len(closest) == 0 {
-			if rand.Intn(2) == 0 {
-				request.From = request.From.AddDate(0, 0, -1)
-			} else {
-				request.To = request.To.AddDate(0, 0, 1)
-			}
-		} else if search.Before(closest[0].UnixMiliSecInTime()) {
-			request.From = request.From.AddDate(0, 0, -1)
-		} else if search.After(closest[0].UnixMiliSecInTime()) {
-			request.To = request.To.AddDate(0, 0, 1)
-		}
-
-		count++
-		if count > 5 {
-			return nil, LimitExceededError
-		}
+		out.ApplyRequest(request)
+		//the limit counts base aggregates, queryCount tells how many were used for the bars
+		out.Truncated = out.QueryCount >= AggregatesMaxResults || len(out.Results) >= AggregatesMaxResults
+		return out, nil
 	}
+	return nil, newAPIError("Aggregates", resp)
 }
@@ -0,0 +1,151 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+//AggregatesMaxResults is the limit sent with every aggregates request, the most polygon allows. It counts the
+//base aggregates bars are built from, minute bars for minute and hour bars and daily bars for longer ones.
+//A response that queried this many was probably cut short
+const AggregatesMaxResults = 50000
+
+//baseBarsPerDay is how many base aggregates of ts a day can hold if the market traded around the clock
+func baseBarsPerDay(ts Timespan) int {
+	switch ts {
+	case Minute, Hour:
+		return 24 * 60
+	}
+	return 1
+}
+
+//ErrInvalidRange is returned by AggregatesRange for requests that cannot be split into chunks
+var ErrInvalidRange = errors.New("invalid aggregates range")
+
+//validateRange checks what AggregatesChunks relies on, a bar width and an ordered window of days
+func validateRange(request AggregatesRequest) error {
+	switch {
+	case request.Multiplier <= 0:
+		return fmt.Errorf("%w: multiplier %d", ErrInvalidRange, request.Multiplier)
+	case !request.Timespan.Valid():
+		return fmt.Errorf("%w: %w", ErrInvalidRange, request.Timespan.validate())
+	case request.From.IsZero() || request.To.IsZero():
+		return fmt.Errorf("%w: from and to are required", ErrInvalidRange)
+	case DateFormat(request.To) < DateFormat(request.From):
+		return fmt.Errorf("%w: to %s is before from %s", ErrInvalidRange, DateFormat(request.To), DateFormat(request.From))
+	}
+	return nil
+}
+
+//AggregatesChunks splits request into consecutive requests of whole new york days, each short enough
+//that it cannot query more than maxBars base aggregates even if the market traded around the clock.
+//A request without a bar width or without an ordered from and to has no chunks
+func AggregatesChunks(request AggregatesRequest, maxBars int) []AggregatesRequest {
+	if validateRange(request) != nil {
+		return []AggregatesRequest{}
+	}
+	if maxBars <= 0 {
+		maxBars = AggregatesMaxResults
+	}
+	days := maxBars / baseBarsPerDay(request.Timespan)
+	if days < 1 {
+		days = 1
+	}
+
+	from := time.Date(request.From.Year(), request.From.Month(), request.From.Day(), 0, 0, 0, 0, AmericaNewYork)
+	to := time.Date(request.To.Year(), request.To.Month(), request.To.Day(), 0, 0, 0, 0, AmericaNewYork)
+	out := []AggregatesRequest{}
+	for !from.After(to) {
+		chunk := request
+		chunk.From = from
+		chunk.To = from.AddDate(0, 0, days-1)
+		if chunk.To.After(to) {
+			chunk.To = to
+		}
+		out = append(out, chunk)
+		from = from.AddDate(0, 0, days)
+	}
+	return out
+}
+
+//AggregatesRange is Aggregates for windows longer than one response can hold. Every chunk is its own request
+//and cache entry, up to AggregatesConcurrency of them are fetched at once. Truncated is set if any chunk was
+func (pc PolygonioClient) AggregatesRange(ctx context.Context, request AggregatesRequest) (*AggregatesResponseContainer, error) {
+	if err := validateRange(request); err != nil {
+		return nil, err
+	}
+	chunks := AggregatesChunks(request, AggregatesMaxResults)
+	results := make([]*AggregatesResponseContainer, len(chunks))
+
+	concurrency := pc.AggregatesConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	var once sync.Once
+	var firstErr error
+	for i := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := pc.Aggregates(ctx, chunks[i])
+			if errors.Is(err, ErrNoResults) {
+				//a chunk of holidays and weekends
+				resp, err = &AggregatesResponseContainer{}, nil
+			}
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = resp
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := &AggregatesResponseContainer{Results: []AggregatesResponse{}}
+	for _, r := range results {
+		//the envelope is the same for every chunk, taken from the first that had bars
+		if len(out.Results) == 0 && len(r.Results) > 0 {
+			out.Ticker, out.Status, out.Adjusted, out.RequestID = r.Ticker, r.Status, r.Adjusted, r.RequestID
+		}
+		out.Results = append(out.Results, r.Results...)
+		out.Truncated = out.Truncated || r.Truncated
+	}
+	sort.SliceStable(out.Results, func(i, j int) bool { return out.Results[i].UnixMiliSec < out.Results[j].UnixMiliSec })
+	deduped := out.Results[:0]
+	for i, ar := range out.Results {
+		if i > 0 && ar.UnixMiliSec == deduped[len(deduped)-1].UnixMiliSec {
+			continue
+		}
+		deduped = append(deduped, ar)
+	}
+	out.Results = deduped
+	out.QueryCount = int64(len(out.Results))
+	out.ResultsCount = int64(len(out.Results))
+	return out, nil
+}
@@ -0,0 +1,251 @@
+package polygonio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAggregatesChunks(t *testing.T) {
+
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2020, month, d, 0, 0, 0, 0, AmericaNewYork)
+	}
+	tests := []struct {
+		name     string
+		request  AggregatesRequest
+		maxBars  int
+		wantFrom []time.Time
+		wantTo   []time.Time
+	}{
+		{
+			name:     "minute bars, 34 days per chunk",
+			request:  AggregatesRequest{Multiplier: 1, Timespan: "minute", From: day(1, 1), To: day(3, 1)},
+			wantFrom: []time.Time{day(1, 1), day(2, 4)},
+			wantTo:   []time.Time{day(2, 3), day(3, 1)},
+		},
+		{
+			name:     "hour bars are counted in base minutes",
+			request:  AggregatesRequest{Multiplier: 1, Timespan: "hour", From: day(1, 1), To: day(3, 1)},
+			wantFrom: []time.Time{day(1, 1), day(2, 4)},
+			wantTo:   []time.Time{day(2, 3), day(3, 1)},
+		},
+		{
+			name:     "across dst",
+			request:  AggregatesRequest{Multiplier: 1, Timespan: "hour", From: day(3, 7), To: day(3, 9)},
+			maxBars:  24,
+			wantFrom: []time.Time{day(3, 7), day(3, 8), day(3, 9)},
+			wantTo:   []time.Time{day(3, 7), day(3, 8), day(3, 9)},
+		},
+		{
+			name:     "at least a day",
+			request:  AggregatesRequest{Multiplier: 1, Timespan: "minute", From: day(3, 7), To: day(3, 8)},
+			maxBars:  10,
+			wantFrom: []time.Time{day(3, 7), day(3, 8)},
+			wantTo:   []time.Time{day(3, 7), day(3, 8)},
+		},
+		{
+			name:     "daily bars in one chunk",
+			request:  AggregatesRequest{Multiplier: 1, Timespan: "day", From: day(1, 1), To: day(12, 31)},
+			wantFrom: []time.Time{day(1, 1)},
+			wantTo:   []time.Time{day(12, 31)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AggregatesChunks(tt.request, tt.maxBars)
+			if len(got) != len(tt.wantFrom) {
+				t.Fatalf("AggregatesChunks() = %v chunks, want %v", len(got), len(tt.wantFrom))
+			}
+			for i := range got {
+				if !got[i].From.Equal(tt.wantFrom[i]) || !got[i].To.Equal(tt.wantTo[i]) {
+					t.Errorf("chunk %v = %v - %v, want %v - %v", i, got[i].From, got[i].To, tt.wantFrom[i], tt.wantTo[i])
+				}
+			}
+		})
+	}
+}
+
+//rangeServer answers every aggregates request with a bar at 9:30 for each requested day
+//and the day before, so neighbouring chunks overlap. FULL gets as many bars as the limit allows,
+//polygon's default of 5000 without one, and BASE used up the limit on base aggregates for few bars
+func rangeServer(calls *int32, inFlight *int32, maxInFlight *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		n := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			max := atomic.LoadInt32(maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		//v2/aggs/ticker/{ticker}/range/{multiplier}/{timespan}/{from}/{to}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		from, _ := time.ParseInLocation("2006-01-02", parts[7], AmericaNewYork)
+		to, _ := time.ParseInLocation("2006-01-02", parts[8], AmericaNewYork)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 {
+			limit = 5000
+		}
+
+		type bar struct {
+			T int64 `json:"t"`
+		}
+		out := struct {
+			Ticker     string `json:"ticker"`
+			Status     string `json:"status"`
+			Adjusted   bool   `json:"adjusted"`
+			RequestID  string `json:"request_id"`
+			QueryCount int    `json:"queryCount"`
+			Results    []bar  `json:"results"`
+		}{Ticker: parts[3], Status: "OK", Adjusted: true, RequestID: parts[7]}
+		if parts[3] == "FULL" {
+			for i := 0; i < limit; i++ {
+				out.Results = append(out.Results, bar{T: from.Add(time.Duration(i) * time.Minute).UnixNano() / int64(time.Millisecond)})
+			}
+		} else {
+			for d := from.AddDate(0, 0, -1); !d.After(to); d = d.AddDate(0, 0, 1) {
+				out.Results = append(out.Results, bar{T: d.Add(9*time.Hour+30*time.Minute).UnixNano() / int64(time.Millisecond)})
+			}
+		}
+		out.QueryCount = len(out.Results)
+		if parts[3] == "BASE" {
+			out.QueryCount = limit
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func TestPolygonioClient_AggregatesRange(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		ticker          string
+		concurrency     int
+		wantBars        int
+		wantTruncated   bool
+		wantMaxInFlight int32
+	}{
+		{name: "sequential", ticker: "AAPL", wantBars: 182, wantMaxInFlight: 1},
+		{name: "concurrent", ticker: "AAPL", concurrency: 2, wantBars: 182, wantMaxInFlight: 2},
+		{name: "truncated", ticker: "FULL", wantTruncated: true, wantMaxInFlight: 1},
+		{name: "truncated base aggregates", ticker: "BASE", wantTruncated: true, wantMaxInFlight: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, inFlight, maxInFlight := int32(0), int32(0), int32(0)
+			server := rangeServer(&calls, &inFlight, &maxInFlight)
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{
+				HTTPClient:            server.Client(),
+				BaseHost:              u.Host,
+				BaseScheme:            u.Scheme,
+				Cacher:                NewMemoryCacher(0, 0),
+				AggregatesConcurrency: tt.concurrency,
+			}
+			request := AggregatesRequest{
+				Ticker:     tt.ticker,
+				Multiplier: 1,
+				Timespan:   "minute",
+				From:       time.Date(2019, 1, 1, 0, 0, 0, 0, AmericaNewYork),
+				To:         time.Date(2019, 6, 30, 0, 0, 0, 0, AmericaNewYork),
+			}
+
+			resp, err := pc.AggregatesRange(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls != 6 {
+				t.Errorf("calls = %v, want 6", calls)
+			}
+			if maxInFlight != tt.wantMaxInFlight {
+				t.Errorf("max in flight = %v, want %v", maxInFlight, tt.wantMaxInFlight)
+			}
+			if resp.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", resp.Truncated, tt.wantTruncated)
+			}
+			if tt.wantTruncated {
+				return
+			}
+
+			//181 days and the day before the first, the overlap of later chunks is dropped
+			if len(resp.Results) != tt.wantBars {
+				t.Errorf("got %v bars, want %v", len(resp.Results), tt.wantBars)
+			}
+			//the envelope of the first chunk, counting the merged bars
+			if resp.Ticker != tt.ticker || resp.Status != "OK" || !resp.Adjusted || resp.RequestID != "2019-01-01" {
+				t.Errorf("envelope = %v %v %v %v", resp.Ticker, resp.Status, resp.Adjusted, resp.RequestID)
+			}
+			if resp.ResultsCount != int64(len(resp.Results)) || resp.QueryCount != int64(len(resp.Results)) {
+				t.Errorf("counts = %v %v, want %v", resp.ResultsCount, resp.QueryCount, len(resp.Results))
+			}
+			for i := 1; i < len(resp.Results); i++ {
+				if resp.Results[i].UnixMiliSec <= resp.Results[i-1].UnixMiliSec {
+					t.Fatalf("bar %v at %v is not after %v", i, resp.Results[i].UnixMiliSecInTime(), resp.Results[i-1].UnixMiliSecInTime())
+				}
+			}
+			if resp.Results[0].timespanDuration != time.Minute {
+				t.Error("chunks were not applied to their request")
+			}
+
+			//each chunk is cached on its own
+			if _, err := pc.AggregatesRange(context.Background(), request); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 6 {
+				t.Errorf("calls = %v after a cached AggregatesRange, want 6", calls)
+			}
+		})
+	}
+}
+
+func TestPolygonioClient_AggregatesRangeInvalid(t *testing.T) {
+
+	day := func(d int) time.Time {
+		return time.Date(2020, 1, d, 0, 0, 0, 0, AmericaNewYork)
+	}
+	tests := []struct {
+		name    string
+		request AggregatesRequest
+	}{
+		{name: "zero multiplier", request: AggregatesRequest{Ticker: "AAPL", Timespan: "minute", From: day(1), To: day(2)}},
+		{name: "unknown timespan", request: AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "fortnight", From: day(1), To: day(2)}},
+		{name: "zero from", request: AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", To: day(2)}},
+		{name: "zero to", request: AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: day(1)}},
+		{name: "to before from", request: AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: day(2), To: day(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, inFlight, maxInFlight := int32(0), int32(0), int32(0)
+			server := rangeServer(&calls, &inFlight, &maxInFlight)
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{HTTPClient: server.Client(), BaseHost: u.Host, BaseScheme: u.Scheme}
+
+			if chunks := AggregatesChunks(tt.request, 0); len(chunks) != 0 {
+				t.Errorf("AggregatesChunks() = %v", chunks)
+			}
+			resp, err := pc.AggregatesRange(context.Background(), tt.request)
+			if !errors.Is(err, ErrInvalidRange) {
+				t.Errorf("AggregatesRange() = %v, %v, want ErrInvalidRange", resp, err)
+			}
+			if calls != 0 {
+				t.Errorf("calls = %v, want 0", calls)
+			}
+		})
+	}
+}
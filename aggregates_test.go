@@ -2,7 +2,10 @@ package polygonio
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
@@ -44,7 +47,7 @@ func TestPolygonioClient_AggregatesRequest(t *testing.T) {
 				To:         time.Date(2019, 01, 02, 0, 0, 0, 0, anyc),
 				Unadjusted: false,
 			}},
-			want: "http://base/v2/aggs/ticker/AAPL/range/1/day/2019-01-01/2019-01-02?apiKey=apiKey&unadjusted=false",
+			want: "http://base/v2/aggs/ticker/AAPL/range/1/day/2019-01-01/2019-01-02?apiKey=apiKey&limit=50000&unadjusted=false",
 		},
 	}
 	for _, tt := range tests {
@@ -225,3 +228,165 @@ func TestAggregatesResponseContainer_ClosestAggregate(t *testing.T) {
 		})
 	}
 }
+
+func TestAggregatesResponseContainer_Unmarshal(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		body        string
+		wantErr     bool
+		wantNoRes   bool
+		wantResults int
+	}{
+		{
+			name:        "full envelope",
+			body:        `{"ticker":"AAPL","status":"OK","queryCount":801,"resultsCount":1,"adjusted":true,"request_id":"abc","results":[{"v":24033,"vw":154.23303,"o":154.4,"c":154.7,"h":154.7,"l":153.01,"t":1546419600000,"n":180}]}`,
+			wantResults: 1,
+		},
+		{name: "no status", body: `{"results":[]}`},
+		{name: "missing results", body: `{"ticker":"AAPL","status":"OK","queryCount":0,"resultsCount":0}`, wantErr: true, wantNoRes: true},
+		{name: "null results", body: `{"status":"OK","results":null}`, wantErr: true, wantNoRes: true},
+		{name: "status not OK", body: `{"status":"ERROR","request_id":"abc","results":[]}`, wantErr: true},
+		{name: "delayed", body: `{"status":"DELAYED","results":[{"t":1546419600000}]}`, wantResults: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arc := AggregatesResponseContainer{}
+			if err := json.Unmarshal([]byte(tt.body), &arc); err != nil {
+				t.Fatal(err)
+			}
+			err := arc.Err()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Err() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNoResults) != tt.wantNoRes {
+				t.Errorf("errors.Is(%v, ErrNoResults) = %v", err, !tt.wantNoRes)
+			}
+			if len(arc.Results) != tt.wantResults {
+				t.Errorf("got %v results", len(arc.Results))
+			}
+		})
+	}
+
+	arc := AggregatesResponseContainer{}
+	json.Unmarshal([]byte(tests[0].body), &arc)
+	want := AggregatesResponseContainer{Ticker: "AAPL", Status: "OK", QueryCount: 801, ResultsCount: 1, Adjusted: true, RequestID: "abc"}
+	got := arc
+	got.Results = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envelope = %+v, want %+v", got, want)
+	}
+	if !arc.Results[0].VWAP.Equal(decimal.RequireFromString("154.23303")) {
+		t.Errorf("VWAP = %v", arc.Results[0].VWAP)
+	}
+}
+
+func TestPolygonioClient_AggregatesCachesEnvelope(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		bodies    []string
+		wantErrs  []bool
+		wantCalls int
+	}{
+		{
+			name:      "error status is not cached",
+			bodies:    []string{`{"status":"ERROR","request_id":"abc","results":[]}`, `{"status":"OK","results":[{"t":1546419600000}]}`},
+			wantErrs:  []bool{true, false},
+			wantCalls: 2,
+		},
+		{
+			name:      "delayed is cached",
+			bodies:    []string{`{"status":"DELAYED","results":[{"t":1546419600000}]}`},
+			wantErrs:  []bool{false, false},
+			wantCalls: 1,
+		},
+		{
+			name:      "nothing traded is cached",
+			bodies:    []string{`{"status":"OK","resultsCount":0}`},
+			wantErrs:  []bool{true, true},
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.bodies[calls%len(tt.bodies)]))
+				calls++
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{HTTPClient: server.Client(), BaseHost: u.Host, BaseScheme: u.Scheme, Cacher: NewMemoryCacher(0, 0)}
+			//a completed session is cached forever
+			request := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: Hour, From: time.Date(2019, 1, 2, 0, 0, 0, 0, AmericaNewYork), To: time.Date(2019, 1, 2, 0, 0, 0, 0, AmericaNewYork)}
+			for i, wantErr := range tt.wantErrs {
+				if _, err := pc.Aggregates(context.Background(), request); (err != nil) != wantErr {
+					t.Errorf("call %v: err = %v, wantErr %v", i, err, wantErr)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("server called %v times, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMerge_VWAP(t *testing.T) {
+
+	bar := func(t int64, volume int64, vwap string) AggregatesResponse {
+		return AggregatesResponse{UnixMiliSec: t, Volume: decimal.NewFromInt(volume), VWAP: decimal.RequireFromString(vwap), timespanDuration: time.Minute}
+	}
+	tests := []struct {
+		name string
+		in   []AggregatesResponse
+		want string
+	}{
+		{name: "weighted by volume", in: []AggregatesResponse{bar(0, 100, "10"), bar(60000, 300, "20")}, want: "17.5"},
+		{name: "no volume", in: []AggregatesResponse{bar(0, 0, "10"), bar(60000, 0, "20")}, want: "15"},
+		{name: "single", in: []AggregatesResponse{bar(0, 100, "10")}, want: "10"},
+		{name: "bar without vw", in: []AggregatesResponse{bar(0, 100, "10"), bar(60000, 300, "0"), bar(120000, 100, "20")}, want: "15"},
+		{name: "no bar has vw", in: []AggregatesResponse{bar(0, 100, "0"), bar(60000, 300, "0")}, want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.in).VWAP; !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("Merge().VWAP = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatesRequest_Sessions(t *testing.T) {
+
+	ny := func(month time.Month, day int, hour int) time.Time {
+		return time.Date(2020, month, day, hour, 0, 0, 0, AmericaNewYork)
+	}
+	tests := []struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		wantFrom string
+		wantTo   string
+	}{
+		{name: "same session", from: ny(4, 24, 9), to: ny(4, 24, 19), wantFrom: "2020-04-24", wantTo: "2020-04-24"},
+		{name: "weekend belongs to friday", from: ny(4, 25, 12), to: ny(4, 26, 23), wantFrom: "2020-04-24", wantTo: "2020-04-24"},
+		{name: "before pre-market", from: ny(4, 27, 3), to: ny(4, 28, 3), wantFrom: "2020-04-24", wantTo: "2020-04-27"},
+		{name: "good friday", from: ny(4, 10, 12), to: ny(4, 13, 12), wantFrom: "2020-04-09", wantTo: "2020-04-13"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AggregatesRequest{Ticker: "AAPL"}.Sessions(tt.from, tt.to)
+			if DateFormat(got.From) != tt.wantFrom || DateFormat(got.To) != tt.wantTo {
+				t.Errorf("Sessions() = %v/%v, want %v/%v", DateFormat(got.From), DateFormat(got.To), tt.wantFrom, tt.wantTo)
+			}
+			if next := DateFormat(ToDate(tt.to)); next <= tt.wantTo {
+				t.Errorf("ToDate() = %v, want after %v", next, tt.wantTo)
+			}
+		})
+	}
+	if got := (HistoricQuotesRequest{}).Session(ny(4, 13, 2)).Date; DateFormat(got) != "2020-04-09" {
+		t.Errorf("HistoricQuotesRequest.Session() = %v", DateFormat(got))
+	}
+}
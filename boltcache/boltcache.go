@@ -0,0 +1,310 @@
+//Package boltcache is a polygonio.CacherV2 keeping every entry in a single bbolt database file
+package boltcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucket = []byte("responses")
+
+//Cacher stores entries under polygonio.RequestKey, the same key FileCacher derives its paths from.
+//Entries are encoded like FileCacher entries so either can read what the other wrote
+type Cacher struct {
+	//how new entries are written, entries of every encoding are read
+	Encoding            polygonio.CacheEncoding
+	DropVolatileHeaders bool
+	//decides when stamped entries expire, nil means SystemClock
+	Clock polygonio.Clock
+
+	path string
+	//held for writing only while Compact swaps the database file
+	mu sync.RWMutex
+	db *bolt.DB
+}
+
+var _ polygonio.CacherV2 = (*Cacher)(nil)
+
+func Open(path string) (*Cacher, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Cacher{path: path, db: db}, nil
+}
+
+func open(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (c *Cacher) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.db.Close()
+}
+
+func (c *Cacher) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+func (c *Cacher) Save(request *http.Request, response *http.Response) error {
+	stored := polygonio.StampFetched(response, c.now())
+	entry := &bytes.Buffer{}
+	if err := polygonio.EncodeCacheEntry(entry, stored, c.Encoding, c.DropVolatileHeaders); err != nil {
+		return err
+	}
+	//EncodeCacheEntry consumed the body, hand the caller a fresh one
+	resp, _, err := polygonio.DecodeCacheEntry(bytes.NewReader(entry.Bytes()), request)
+	if err != nil {
+		return err
+	}
+	response.Body = resp.Body
+
+	return c.put(polygonio.RequestKey(request), entry.Bytes())
+}
+
+func (c *Cacher) put(key string, entry []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), entry)
+	})
+}
+
+func (c *Cacher) Get(request *http.Request) (*http.Response, error) {
+	var entry []byte
+	c.mu.RLock()
+	err := c.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucket).Get([]byte(polygonio.RequestKey(request))); v != nil {
+			//only valid inside the transaction
+			entry = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	c.mu.RUnlock()
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	resp, _, err := polygonio.DecodeCacheEntry(bytes.NewReader(entry), request)
+	if err != nil {
+		return nil, err
+	}
+	if polygonio.Expired(resp, c.now()) {
+		resp.Body.Close()
+		return nil, nil
+	}
+	return resp, nil
+}
+
+func (c *Cacher) GetContext(ctx context.Context, request *http.Request) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(request)
+}
+
+func (c *Cacher) SaveContext(ctx context.Context, request *http.Request, response *http.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Save(request, response)
+}
+
+func (c *Cacher) Delete(ctx context.Context, key string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+}
+
+func (c *Cacher) Stat(ctx context.Context, key string) (polygonio.CacheEntry, error) {
+	var stored []byte
+	c.mu.RLock()
+	err := c.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucket).Get([]byte(key)); v != nil {
+			stored = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	c.mu.RUnlock()
+	if err != nil {
+		return polygonio.CacheEntry{}, err
+	}
+	if stored == nil {
+		return polygonio.CacheEntry{}, &polygonio.NotCachedError{Key: key}
+	}
+	return stat(key, stored)
+}
+
+//stat fills what the key and size tell when the entry cannot be decoded, together with the error
+func stat(key string, stored []byte) (polygonio.CacheEntry, error) {
+	entry := polygonio.CacheEntry{Key: key}
+	entry.Endpoint, entry.Ticker = polygonio.KeyEndpoint(key)
+	resp, _, err := polygonio.DecodeCacheEntry(bytes.NewReader(stored), nil)
+	if err == nil {
+		resp.Body.Close()
+		entry = polygonio.NewCacheEntry(key, resp)
+	}
+	entry.Size = int64(len(stored))
+	return entry, err
+}
+
+//Walk visits entries in key order, entries that cannot be decoded are visited with what the key tells.
+//The entries are read first and fn runs outside of the transaction so it may Delete
+func (c *Cacher) Walk(ctx context.Context, fn func(entry polygonio.CacheEntry) error) error {
+	entries := []polygonio.CacheEntry{}
+	c.mu.RLock()
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k []byte, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			entry, _ := stat(string(k), v)
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Compact rewrites the database without the free pages left behind by overwritten entries
+func (c *Cacher) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp := c.path + ".compact"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0644, nil)
+	if err != nil {
+		return err
+	}
+	if err := bolt.Compact(dst, c.db, 64<<20); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	//from here on c.db is closed, whatever fails the database at path is opened again so the
+	//cacher keeps working, compacted when the rename happened and as it was otherwise
+	err = c.db.Close()
+	if err == nil {
+		err = rename(tmp, c.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	db, openErr := open(c.path)
+	if openErr != nil {
+		return fmt.Errorf("reopen %s after compacting: %w", c.path, openErr)
+	}
+	c.db = db
+	return err
+}
+
+//tests make the rename fail
+var rename = os.Rename
+
+//entries per transaction while importing
+const importBatch = 1000
+
+//ImportFileCacher copies every entry below dir written by a FileCacher, entries are copied as stored
+func (c *Cacher) ImportFileCacher(dir string) (int, error) {
+	fc := polygonio.FileCacher{Dir: dir}
+	keys := [][]byte{}
+	entries := [][]byte{}
+	count := 0
+
+	flush := func() error {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		err := c.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(bucket)
+			for i := range keys {
+				if err := b.Put(keys[i], entries[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			count += len(keys)
+		}
+		keys, entries = keys[:0], entries[:0]
+		return err
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		key, err := fc.Key(path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		entry := &bytes.Buffer{}
+		_, err = entry.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("import %s: %w", path, err)
+		}
+
+		keys = append(keys, []byte(key))
+		entries = append(entries, entry.Bytes())
+		if len(keys) == importBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, flush()
+}
@@ -0,0 +1,273 @@
+package boltcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+)
+
+func response(r *http.Request, body string) *http.Response {
+	raw := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), r)
+	if err != nil {
+		panic(err)
+	}
+	return resp
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "boltcache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCacher_SaveGet(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c, err := Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Encoding = polygonio.EncodingGzip
+
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL?apiKey=secret", nil)
+	resp := response(r, "aapl")
+	if err := c.Save(r, resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := body(t, resp); got != "aapl" {
+		t.Errorf("body after Save() = %v", got)
+	}
+
+	//a different apiKey is the same entry
+	other, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL?apiKey=other", nil)
+	cached, err := c.Get(other)
+	if err != nil || cached == nil {
+		t.Fatalf("Get() = %v, %v", cached, err)
+	}
+	if got := body(t, cached); got != "aapl" {
+		t.Errorf("Get() body = %v", got)
+	}
+
+	missing, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/MSFT", nil)
+	if cached, err := c.Get(missing); cached != nil || err != nil {
+		t.Errorf("Get() = %v, %v, want a miss", cached, err)
+	}
+
+	if err := c.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	cached, err = c.Get(r)
+	if err != nil || cached == nil || body(t, cached) != "aapl" {
+		t.Errorf("Get() after Compact() = %v, %v", cached, err)
+	}
+}
+
+func TestCacher_CompactRenameFails(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c, err := Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL?apiKey=secret", nil)
+	if err := c.Save(r, response(r, "aapl")); err != nil {
+		t.Fatal(err)
+	}
+
+	injected := errors.New("injected")
+	rename = func(string, string) error { return injected }
+	defer func() { rename = os.Rename }()
+	if err := c.Compact(); !errors.Is(err, injected) {
+		t.Errorf("Compact() = %v, want %v", err, injected)
+	}
+
+	//the original database is open again
+	cached, err := c.Get(r)
+	if err != nil || cached == nil || body(t, cached) != "aapl" {
+		t.Fatalf("Get() after a failed Compact() = %v, %v", cached, err)
+	}
+	other, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/MSFT?apiKey=secret", nil)
+	if err := c.Save(other, response(other, "msft")); err != nil {
+		t.Errorf("Save() after a failed Compact() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache.db.compact")); !os.IsNotExist(err) {
+		t.Errorf("compacted copy left behind, %v", err)
+	}
+}
+
+func TestCacher_Concurrent(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c, err := Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				r, _ := http.NewRequest("GET", fmt.Sprintf("http://base/v1/last_quote/stocks/T%d", i), nil)
+				if resp, err := c.Get(r); err != nil {
+					t.Error(err)
+				} else if resp != nil {
+					resp.Body.Close()
+					continue
+				}
+				if err := c.Save(r, response(r, fmt.Sprint(i))); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestCacher_ImportFileCacher(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	fc := polygonio.FileCacher{Dir: filepath.Join(dir, "files"), FileCacherIo: polygonio.OsFileCacherIo{}}
+	requests := []*http.Request{}
+	for i, ticker := range []string{"AAPL", "MSFT", "TSLA"} {
+		r, _ := http.NewRequest("GET", "http://base/v2/ticks/stocks/nbbo/"+ticker+"/2018-02-02?apiKey=secret&limit=0", nil)
+		if i == 2 {
+			fc.Encoding = polygonio.EncodingGzip
+		}
+		if err := fc.Save(r, response(r, ticker)); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, r)
+	}
+
+	c, err := Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	count, err := c.ImportFileCacher(fc.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("ImportFileCacher() = %v, want 3", count)
+	}
+	for i, ticker := range []string{"AAPL", "MSFT", "TSLA"} {
+		cached, err := c.Get(requests[i])
+		if err != nil || cached == nil {
+			t.Fatalf("Get(%v) = %v, %v", ticker, cached, err)
+		}
+		if got := body(t, cached); got != ticker {
+			t.Errorf("Get(%v) body = %v", ticker, got)
+		}
+	}
+
+	//nothing can be written to a closed database
+	c.Close()
+	count, err = c.ImportFileCacher(fc.Dir)
+	if err == nil || count != 0 {
+		t.Errorf("ImportFileCacher() into a closed database = %v, %v", count, err)
+	}
+}
+
+type fixedClock time.Time
+
+func (fc fixedClock) Now() time.Time {
+	return time.Time(fc)
+}
+
+func (fc fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func TestCacher_CacherV2(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c, err := Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	now := time.Date(2020, 4, 24, 14, 0, 0, 0, time.UTC)
+	c.Clock = fixedClock(now)
+	c.Encoding = polygonio.EncodingGzip
+
+	ctx := context.Background()
+	keys := []string{}
+	for _, ticker := range []string{"MSFT", "AAPL"} {
+		r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/"+ticker+"?apiKey=secret", nil)
+		resp := response(r, ticker)
+		if err := c.SaveContext(ctx, r, resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get(polygonio.FetchedHeader) != "" {
+			t.Error("Save() stamped the caller's response")
+		}
+		keys = append(keys, polygonio.RequestKey(r))
+	}
+
+	entry, err := c.Stat(ctx, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Key != keys[0] || entry.Endpoint != "last_quote" || entry.Ticker != "MSFT" || !entry.Fetched.Equal(now) || entry.Size == 0 {
+		t.Errorf("Stat() = %+v", entry)
+	}
+
+	//key order, deleting while walking
+	visited := []string{}
+	if err := c.Walk(ctx, func(entry polygonio.CacheEntry) error {
+		visited = append(visited, entry.Ticker)
+		return c.Delete(ctx, entry.Key)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(visited, " ") != "AAPL MSFT" {
+		t.Errorf("Walk() visited %v", visited)
+	}
+	if _, err := c.Stat(ctx, keys[0]); !errors.Is(err, polygonio.ErrNotCached) {
+		t.Errorf("Stat() after Delete() err = %v, want ErrNotCached", err)
+	}
+	if err := c.Delete(ctx, keys[0]); err != nil {
+		t.Errorf("Delete() of a missing key = %v", err)
+	}
+}
@@ -1,52 +1,109 @@
 package polygonio
 
 import (
-	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 )
 
+//Doer is the network half of DoCache, *http.Client satisfies it
+type Doer interface {
+	Do(r *http.Request) (*http.Response, error)
+}
+
 func DoCache(client *http.Client, r *http.Request, cacheable bool, cacher Cacher) (*http.Response, error) {
-	if r.Method == "GET" && cacheable && cacher != nil {
-		resp, err := cacher.Get(r)
-		if resp != nil && err == nil {
-			return resp, err
-		}
-	}
+	return DoCacheWith(client, r, cacheable, cacher)
+}
 
-	resp, err := client.Do(r)
-	if err != nil {
-		return nil, err
-	}
-	if r.Method == "GET" && resp.StatusCode == 200 && cacheable && cacher != nil {
-		if err := cacher.Save(r, resp); err != nil {
-			return nil, err
-		}
+//DoCacheWith is DoCache with the network request made by doer
+func DoCacheWith(doer Doer, r *http.Request, cacheable bool, cacher Cacher) (*http.Response, error) {
+	freshness := NoStore
+	if cacheable {
+		freshness = Immutable
 	}
-	return resp, nil
+	return DoCacheFreshness(doer, r, freshness, cacher)
+}
+
+//DoCacheFreshness is DoCacheWith with the cache entry stamped with freshness, the mode is taken from the request context
+func DoCacheFreshness(doer Doer, r *http.Request, freshness Freshness, cacher Cacher) (*http.Response, error) {
+	mode, _ := CacheModeFrom(r.Context())
+	return DoCacheMode(doer, r, freshness, cacher, mode)
 }
 
 type FileCacher struct {
 	Dir          string
 	FileCacherIo FileCacherIo
+	//decides when stamped entries expire, nil means SystemClock
+	Clock Clock
+	//how new entries are written, entries of every encoding are read
+	Encoding            CacheEncoding
+	DropVolatileHeaders bool
 }
 
+//FileCacherIo is the storage of a FileCacher, missing files are reported with errors os.IsNotExist recognizes
 type FileCacherIo interface {
 	AtomicWrite(dir string, filename string, write func(w io.Writer) error) error
 	Read(filepath string) (io.ReadCloser, error)
 }
 
-func (fc FileCacher) FilePath(request *http.Request) (dir string, fn string) {
+//FileCacherIoV2 is a FileCacherIo that can also remove, stat and walk what it stores. With a FileCacherIo
+//that is not one FileCacher can Stat by reading the entry, without its Size, but cannot Delete or Walk
+type FileCacherIoV2 interface {
+	FileCacherIo
+	//removes a file or an empty directory, like os.Remove
+	Remove(filepath string) error
+	Stat(filepath string) (os.FileInfo, error)
+	//visits files in lexical order like filepath.Walk
+	Walk(root string, fn filepath.WalkFunc) error
+}
+
+var _ FileCacherIoV2 = OsFileCacherIo{}
+
+func (fc FileCacher) fileCacherIoV2() (FileCacherIoV2, bool) {
+	v2, ok := fc.FileCacherIo.(FileCacherIoV2)
+	return v2, ok
+}
+
+//requestKey normalizes a request the same way for every cache, the apiKey is replaced
+//and the query is sorted by Encode
+func requestKey(request *http.Request) (path []string, query string) {
 	q := request.URL.Query()
 	q.Set("apiKey", "X")
-	path := []string{fc.Dir, request.URL.Scheme, request.URL.Host}
+	path = []string{request.URL.Scheme, request.URL.Host}
 	path = append(path, strings.Split(request.URL.Path, "/")...)
-	return filepath.Join(path...), q.Encode() + ".json"
+	return path, q.Encode()
+}
+
+//RequestKey identifies a request independently of the apiKey it was made with
+func RequestKey(request *http.Request) string {
+	_, query := requestKey(request)
+	return request.URL.Scheme + "://" + request.URL.Host + request.URL.Path + "?" + query
+}
+
+func (fc FileCacher) FilePath(request *http.Request) (dir string, fn string) {
+	path, query := requestKey(request)
+	return filepath.Join(append([]string{fc.Dir}, path...)...), query + ".json"
+}
+
+//Key is the inverse of FilePath, the RequestKey of the entry stored at path
+func (fc FileCacher) Key(path string) (string, error) {
+	rel, err := filepath.Rel(fc.Dir, path)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 3 || parts[0] == ".." || !strings.HasSuffix(parts[len(parts)-1], ".json") {
+		return "", fmt.Errorf("%s is not a cache entry of %s", path, fc.Dir)
+	}
+	query := strings.TrimSuffix(parts[len(parts)-1], ".json")
+	return parts[0] + "://" + parts[1] + "/" + strings.Join(parts[2:len(parts)-1], "/") + "?" + query, nil
 }
 
 func (fc FileCacher) Save(request *http.Request, response *http.Response) error {
@@ -65,10 +122,11 @@ func (fc FileCacher) Save(request *http.Request, response *http.Response) error
 		reader.Reset(body)
 	}()
 
+	stored := StampFetched(response, fc.now())
 	dir, fn := fc.FilePath(request)
 
 	return fc.FileCacherIo.AtomicWrite(dir, fn, func(w io.Writer) error {
-		return response.Write(w)
+		return EncodeCacheEntry(w, stored, fc.Encoding, fc.DropVolatileHeaders)
 	})
 }
 
@@ -82,55 +140,145 @@ func (fc FileCacher) Get(request *http.Request) (*http.Response, error) {
 	}
 	defer f.Close()
 
-	fb, err := ioutil.ReadAll(f)
+	resp, _, err := DecodeCacheEntry(f, request)
 	if err != nil {
 		return nil, err
 	}
+	if Expired(resp, fc.now()) {
+		resp.Body.Close()
+		return nil, nil
+	}
+	return resp, nil
+}
+
+func (fc FileCacher) now() time.Time {
+	if fc.Clock == nil {
+		return time.Now()
+	}
+	return fc.Clock.Now()
+}
 
-	return http.ReadResponse(bufio.NewReader(bytes.NewReader(fb)), request)
+//OsFileCacherIo writes through a temp file in the destination directory so the rename stays
+//on one filesystem. Concurrent writers of the same key each rename a complete file, the last one wins
+type OsFileCacherIo struct {
+	//nil means the os package, tests inject faults through it
+	fs fileSystem
 }
 
-type OsFileCacherIo struct{}
+type fileSystem interface {
+	MkdirAll(path string, perm os.FileMode) error
+	TempFile(dir string, pattern string) (tempFile, error)
+	Rename(oldpath string, newpath string) error
+	Remove(name string) error
+	SyncDir(dir string) error
+}
+
+type tempFile interface {
+	io.Writer
+	Name() string
+	Chmod(mode os.FileMode) error
+	Sync() error
+	Close() error
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFileSystem) TempFile(dir string, pattern string) (tempFile, error) {
+	return ioutil.TempFile(dir, pattern)
+}
+
+func (osFileSystem) Rename(oldpath string, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) SyncDir(dir string) error {
+	//directories cannot be opened for syncing on windows, the rename is durable there without it
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
 
-func (OsFileCacherIo) AtomicWrite(dir string, filename string, write func(w io.Writer) error) error {
+func (ofc OsFileCacherIo) fileSystem() fileSystem {
+	if ofc.fs == nil {
+		return osFileSystem{}
+	}
+	return ofc.fs
+}
+
+func (ofc OsFileCacherIo) AtomicWrite(dir string, filename string, write func(w io.Writer) error) error {
+	fs := ofc.fileSystem()
 
 	//create file destination directories
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		panic(err)
+	if err := fs.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("cache mkdir: %w", err)
 	}
 
-	f, err := ioutil.TempFile("", "atomic-")
+	//a dot prefix keeps half written files out of anything walking for *.json
+	f, err := fs.TempFile(dir, ".atomic-")
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("cache temp file: %w", err)
 	}
+	renamed := false
 	defer func() {
-		// Clean up (best effort) in case we are returning with an error:
-		// Prevent file descriptor leaks.
+		//clean up (best effort) when returning with an error, closing twice is harmless
 		f.Close()
-		// Remove the tempfile to avoid filling up the file system.
-		os.Remove(f.Name())
+		if !renamed {
+			fs.Remove(f.Name())
+		}
 	}()
 
 	if err := write(f); err != nil {
-		panic(err)
+		return fmt.Errorf("cache write %s: %w", filename, err)
+	}
+	if err := f.Chmod(0644); err != nil {
+		return fmt.Errorf("cache chmod %s: %w", filename, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cache sync %s: %w", filename, err)
 	}
-
-	f.Chmod(0644)
-	f.Sync()
-
 	if err := f.Close(); err != nil {
-		panic(err)
+		return fmt.Errorf("cache close %s: %w", filename, err)
 	}
 
 	//rename temp file to destination file
-	if err := os.Rename(f.Name(), filepath.Join(dir, filename)); err != nil {
-		panic(err)
+	if err := fs.Rename(f.Name(), filepath.Join(dir, filename)); err != nil {
+		return fmt.Errorf("cache rename %s: %w", filename, err)
 	}
+	renamed = true
 
+	//make the rename itself durable
+	if err := fs.SyncDir(dir); err != nil {
+		return fmt.Errorf("cache sync dir: %w", err)
+	}
 	return nil
-
 }
 
 func (OsFileCacherIo) Read(filepath string) (io.ReadCloser, error) {
 	return os.Open(filepath)
 }
+
+func (ofc OsFileCacherIo) Remove(filepath string) error {
+	return ofc.fileSystem().Remove(filepath)
+}
+
+func (OsFileCacherIo) Stat(filepath string) (os.FileInfo, error) {
+	return os.Stat(filepath)
+}
+
+func (OsFileCacherIo) Walk(root string, fn filepath.WalkFunc) error {
+	return filepath.Walk(root, fn)
+}
@@ -2,11 +2,18 @@ package polygonio
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type TestIoer struct {
@@ -104,3 +111,176 @@ func TestFileCacher_Get(t *testing.T) {
 	}
 
 }
+
+var errInjected = errors.New("injected")
+
+//faultyFileSystem fails the named operation, everything else goes to the os
+type faultyFileSystem struct {
+	osFileSystem
+	fail string
+}
+
+type faultyFile struct {
+	*os.File
+	fail string
+}
+
+func (ffs faultyFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	if ffs.fail == "mkdir" {
+		return errInjected
+	}
+	return ffs.osFileSystem.MkdirAll(path, perm)
+}
+
+func (ffs faultyFileSystem) TempFile(dir string, pattern string) (tempFile, error) {
+	if ffs.fail == "temp" {
+		return nil, errInjected
+	}
+	f, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return faultyFile{File: f, fail: ffs.fail}, nil
+}
+
+func (ffs faultyFileSystem) Rename(oldpath string, newpath string) error {
+	if ffs.fail == "rename" {
+		return errInjected
+	}
+	return ffs.osFileSystem.Rename(oldpath, newpath)
+}
+
+func (ffs faultyFileSystem) SyncDir(dir string) error {
+	if ffs.fail == "syncdir" {
+		return errInjected
+	}
+	return ffs.osFileSystem.SyncDir(dir)
+}
+
+func (ff faultyFile) Write(p []byte) (int, error) {
+	if ff.fail == "write" {
+		return 0, errInjected
+	}
+	return ff.File.Write(p)
+}
+
+func (ff faultyFile) Sync() error {
+	if ff.fail == "sync" {
+		return errInjected
+	}
+	return ff.File.Sync()
+}
+
+func TestOsFileCacherIo_AtomicWrite(t *testing.T) {
+
+	tests := []struct {
+		fail        string
+		wantContent string
+	}{
+		{fail: "", wantContent: "new"},
+		{fail: "mkdir", wantContent: "old"},
+		{fail: "temp", wantContent: "old"},
+		{fail: "write", wantContent: "old"},
+		{fail: "sync", wantContent: "old"},
+		{fail: "rename", wantContent: "old"},
+		//the rename happened, only its durability is unknown
+		{fail: "syncdir", wantContent: "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fail, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "atomic-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "entry.json"), []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			ofc := OsFileCacherIo{fs: faultyFileSystem{fail: tt.fail}}
+			err = ofc.AtomicWrite(dir, "entry.json", func(w io.Writer) error {
+				_, err := w.Write([]byte("new"))
+				return err
+			})
+			if tt.fail == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.fail != "" && !errors.Is(err, errInjected) {
+				t.Errorf("err = %v, want %v", err, errInjected)
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, "entry.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.wantContent {
+				t.Errorf("content = %q, want %q", b, tt.wantContent)
+			}
+
+			files, _ := ioutil.ReadDir(dir)
+			if len(files) != 1 {
+				t.Errorf("temp files left behind: %v", len(files)-1)
+			}
+		})
+	}
+}
+
+func TestOsFileCacherIo_ConcurrentWriters(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "atomic-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ofc := OsFileCacherIo{}
+	contents := []string{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		content := strings.Repeat(strconv.Itoa(i), 64<<10)
+		contents = append(contents, content)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ofc.AtomicWrite(filepath.Join(dir, "a", "b"), "entry.json", func(w io.Writer) error {
+				_, err := io.WriteString(w, content)
+				return err
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a", "b", "entry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole := false
+	for _, c := range contents {
+		whole = whole || string(b) == c
+	}
+	if !whole {
+		t.Error("entry is a mix of writers")
+	}
+}
+
+func TestFileCacher_Key(t *testing.T) {
+
+	fc := FileCacher{Dir: "Test"}
+	pc := PolygonioClient{APIKey: "secret", BaseHost: "base", BaseScheme: "http"}
+	r := pc.HistoricQuotesRequest(context.Background(), HistoricQuotesRequest{Ticker: "AAPL", Date: time.Date(2018, 02, 02, 0, 0, 0, 0, time.UTC)})
+
+	dir, fn := fc.FilePath(r)
+	key, err := fc.Key(filepath.Join(dir, fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != RequestKey(r) {
+		t.Errorf("Key() = %v, want %v", key, RequestKey(r))
+	}
+
+	if _, err := fc.Key(filepath.Join("Other", "http", "base", "x.json")); err == nil {
+		t.Error("expected an error outside Dir")
+	}
+}
@@ -0,0 +1,133 @@
+package polygonio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+type CacheEncoding int
+
+const (
+	//the plain http/1.1 wire dump FileCacher has always written, readable without a header
+	EncodingRaw CacheEncoding = iota
+	EncodingGzip
+)
+
+//encoded entries start with a header line such as "PGIO/1 gzip\n", raw dumps start with "HTTP/"
+const cacheEntryMagic = "PGIO/"
+const cacheEntryVersion = 1
+
+//VolatileHeaders change on every response without changing what it means, dropped when FileCacher.DropVolatileHeaders is set
+var VolatileHeaders = []string{
+	"Connection",
+	"Date",
+	"Keep-Alive",
+	"Server",
+	"Set-Cookie",
+	"Strict-Transport-Security",
+	"Vary",
+	"X-Request-Id",
+}
+
+func (ce CacheEncoding) String() string {
+	switch ce {
+	case EncodingRaw:
+		return "raw"
+	case EncodingGzip:
+		return "gzip"
+	}
+	return fmt.Sprintf("CacheEncoding(%d)", int(ce))
+}
+
+func ParseCacheEncoding(in string) (CacheEncoding, error) {
+	switch in {
+	case "raw", "":
+		return EncodingRaw, nil
+	case "gzip":
+		return EncodingGzip, nil
+	}
+	return EncodingRaw, fmt.Errorf("unknown cache encoding %q", in)
+}
+
+//EncodeCacheEntry writes response as a cache entry, the response body is consumed
+func EncodeCacheEntry(w io.Writer, response *http.Response, encoding CacheEncoding, dropVolatileHeaders bool) error {
+	if dropVolatileHeaders {
+		//the caller keeps its headers
+		copied := *response
+		copied.Header = response.Header.Clone()
+		for _, h := range VolatileHeaders {
+			copied.Header.Del(h)
+		}
+		response = &copied
+	}
+
+	switch encoding {
+	case EncodingRaw:
+		return response.Write(w)
+	case EncodingGzip:
+		if _, err := fmt.Fprintf(w, "%s%d %s\n", cacheEntryMagic, cacheEntryVersion, encoding); err != nil {
+			return err
+		}
+		gz := gzip.NewWriter(w)
+		if err := response.Write(gz); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+	return fmt.Errorf("unknown cache encoding %v", encoding)
+}
+
+//DecodeCacheEntry reads an entry written by any version of FileCacher
+func DecodeCacheEntry(r io.Reader, request *http.Request) (*http.Response, CacheEncoding, error) {
+	br := bufio.NewReader(r)
+	peek, _ := br.Peek(len(cacheEntryMagic))
+	if string(peek) != cacheEntryMagic {
+		resp, err := readResponse(br, request)
+		return resp, EncodingRaw, err
+	}
+
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, EncodingRaw, err
+	}
+	var version int
+	var name string
+	if _, err := fmt.Sscanf(strings.TrimSpace(line), cacheEntryMagic+"%d %s", &version, &name); err != nil {
+		return nil, EncodingRaw, fmt.Errorf("bad cache entry header %q: %w", line, err)
+	}
+	if version > cacheEntryVersion {
+		return nil, EncodingRaw, fmt.Errorf("cache entry version %d is newer than %d", version, cacheEntryVersion)
+	}
+	encoding, err := ParseCacheEncoding(name)
+	if err != nil {
+		return nil, EncodingRaw, err
+	}
+
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, encoding, err
+		}
+		defer gz.Close()
+		resp, err := readResponse(gz, request)
+		return resp, encoding, err
+	}
+	resp, err := readResponse(br, request)
+	return resp, encoding, err
+}
+
+//readResponse reads the whole entry so the returned body outlives r
+func readResponse(r io.Reader, request *http.Request) (*http.Response, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), request)
+}
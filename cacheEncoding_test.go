@@ -0,0 +1,98 @@
+package polygonio
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFileCacher_Encoding(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		encoding     CacheEncoding
+		drop         bool
+		wantPrefix   string
+		wantDateKept bool
+	}{
+		{name: "raw", encoding: EncodingRaw, wantPrefix: "HTTP/1.1 200 OK", wantDateKept: true},
+		{name: "gzip", encoding: EncodingGzip, wantPrefix: "PGIO/1 gzip\n", wantDateKept: true},
+		{name: "gzip drop headers", encoding: EncodingGzip, drop: true, wantPrefix: "PGIO/1 gzip\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := &bytes.Buffer{}
+			fc := FileCacher{Dir: "Test", Encoding: tt.encoding, DropVolatileHeaders: tt.drop, FileCacherIo: &TestIoer{
+				atomicWrite: func(dir string, filename string, write func(w io.Writer) error) error {
+					return write(stored)
+				},
+				read: func(filepath string) (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(stored.Bytes())), nil
+				},
+			}}
+
+			r, _ := http.NewRequest("GET", "http://www.google.com", nil)
+			resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(googleResponse)), r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := fc.Save(r, resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Header.Get("Date") == "" {
+				t.Error("Save() changed the caller's headers")
+			}
+			if !strings.HasPrefix(stored.String(), tt.wantPrefix) {
+				t.Errorf("stored %q, want prefix %q", stored.String()[:20], tt.wantPrefix)
+			}
+
+			out, err := fc.Get(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Body.Close()
+			if got := readBody(t, out); got != googleBody {
+				t.Errorf("body = %q, want %q", got, googleBody)
+			}
+			if kept := out.Header.Get("Date") != ""; kept != tt.wantDateKept {
+				t.Errorf("Date kept = %v, want %v", kept, tt.wantDateKept)
+			}
+			if out.Header.Get("Content-Type") == "" {
+				t.Error("Content-Type dropped")
+			}
+		})
+	}
+}
+
+func TestDecodeCacheEntry(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		entry        string
+		wantEncoding CacheEncoding
+		wantErr      bool
+	}{
+		{name: "legacy raw", entry: googleResponse, wantEncoding: EncodingRaw},
+		{name: "newer version", entry: "PGIO/9 gzip\n", wantErr: true},
+		{name: "unknown encoding", entry: "PGIO/1 zip\n", wantErr: true},
+		{name: "bad header", entry: "PGIO/x\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, encoding, err := DecodeCacheEntry(strings.NewReader(tt.entry), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeCacheEntry() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				resp.Body.Close()
+				if encoding != tt.wantEncoding {
+					t.Errorf("encoding = %v, want %v", encoding, tt.wantEncoding)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//FetchedHeader is stamped by StampFetched when an entry is saved, Stat reports it as CacheEntry.Fetched
+const FetchedHeader = "X-Polygonio-Fetched"
+
+//StampFetched is the copy of resp a cacher stores, with FetchedHeader set to now unless resp already has it.
+//resp keeps its headers, the copy shares its body
+func StampFetched(resp *http.Response, now time.Time) *http.Response {
+	stored := *resp
+	if resp.Header.Get(FetchedHeader) == "" {
+		stored.Header = resp.Header.Clone()
+		if stored.Header == nil {
+			stored.Header = http.Header{}
+		}
+		stored.Header.Set(FetchedHeader, now.UTC().Format(http.TimeFormat))
+	}
+	return &stored
+}
+
+//ErrCacherUnsupported is returned by the CacherV2From adapter for operations the old Cacher cannot do
+var ErrCacherUnsupported = errors.New("not supported by this cacher")
+
+//CacheEntry describes a stored response without its body
+type CacheEntry struct {
+	//RequestKey of the cached request
+	Key string
+	//aggregates, nbbo, trades, last_quote or unknown
+	Endpoint string
+	Ticker   string
+	//stored size, encoded, 0 when the cacher cannot tell
+	Size int64
+	//zero when the entry predates FetchedHeader and the cacher cannot tell
+	Fetched time.Time
+	//zero never expires
+	Expires time.Time
+}
+
+//TTL is how long the entry was allowed to be served, 0 for entries that never expire
+func (ce CacheEntry) TTL() time.Duration {
+	if ce.Expires.IsZero() || ce.Fetched.IsZero() {
+		return 0
+	}
+	return ce.Expires.Sub(ce.Fetched)
+}
+
+//CacherV2 is a Cacher that takes a context and can be enumerated and pruned, keys are RequestKey values.
+//DoCache and PolygonioClient use it through CacherV2From
+type CacherV2 interface {
+	GetContext(ctx context.Context, request *http.Request) (*http.Response, error)
+	//should only return error if response is unuseable
+	SaveContext(ctx context.Context, request *http.Request, response *http.Response) error
+	//deleting a key that is not cached is not an error
+	Delete(ctx context.Context, key string) error
+	//a missing key is a *NotCachedError
+	Stat(ctx context.Context, key string) (CacheEntry, error)
+	//fn returning an error stops the walk with that error
+	Walk(ctx context.Context, fn func(entry CacheEntry) error) error
+}
+
+var _ CacherV2 = FileCacher{}
+var _ CacherV2 = (*MemoryCacher)(nil)
+
+//CacherV2From returns cacher itself when it implements CacherV2, otherwise an adapter.
+//The adapter can Stat by reading the entry, without its Size, but cannot Delete or Walk
+func CacherV2From(cacher Cacher) CacherV2 {
+	if cacher == nil {
+		return nil
+	}
+	if v2, ok := cacher.(CacherV2); ok {
+		return v2
+	}
+	return cacherAdapter{cacher}
+}
+
+type cacherAdapter struct {
+	Cacher
+}
+
+func (ca cacherAdapter) GetContext(ctx context.Context, request *http.Request) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ca.Get(request)
+}
+
+func (ca cacherAdapter) SaveContext(ctx context.Context, request *http.Request, response *http.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ca.Save(request, response)
+}
+
+func (ca cacherAdapter) Delete(ctx context.Context, key string) error {
+	return ErrCacherUnsupported
+}
+
+func (ca cacherAdapter) Stat(ctx context.Context, key string) (CacheEntry, error) {
+	request, err := keyRequest(ctx, key)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	resp, err := ca.GetContext(ctx, request)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	if resp == nil {
+		return CacheEntry{}, &NotCachedError{Key: key}
+	}
+	resp.Body.Close()
+	//the decoded response says nothing about how large the stored entry is
+	return NewCacheEntry(key, resp), nil
+}
+
+func (ca cacherAdapter) Walk(ctx context.Context, fn func(entry CacheEntry) error) error {
+	return ErrCacherUnsupported
+}
+
+//keyRequest turns a RequestKey back into a request that has the same key
+func keyRequest(ctx context.Context, key string) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, "GET", key, nil)
+}
+
+//KeyEndpoint maps a RequestKey back to the endpoint and ticker it was made for
+func KeyEndpoint(key string) (endpoint string, ticker string) {
+	if i := strings.Index(key, "://"); i >= 0 {
+		key = key[i+3:]
+	}
+	if i := strings.IndexByte(key, '?'); i >= 0 {
+		key = key[:i]
+	}
+	p := strings.Split(key, "/")
+	if len(p) < 2 {
+		return "unknown", ""
+	}
+	//drop the host
+	p = p[1:]
+	switch {
+	case len(p) >= 4 && p[0] == "v2" && p[1] == "aggs" && p[2] == "ticker":
+		return "aggregates", p[3]
+	case len(p) >= 5 && p[0] == "v2" && p[1] == "ticks" && p[2] == "stocks" && (p[3] == "nbbo" || p[3] == "trades"):
+		return p[3], p[4]
+	case len(p) >= 4 && p[0] == "v1" && p[1] == "last_quote" && p[2] == "stocks":
+		return "last_quote", p[3]
+	}
+	return "unknown", ""
+}
+
+//NewCacheEntry is what a cached response tells about itself, for CacherV2 implementations to fill in Size
+func NewCacheEntry(key string, resp *http.Response) CacheEntry {
+	entry := CacheEntry{Key: key}
+	entry.Endpoint, entry.Ticker = KeyEndpoint(key)
+	if fetched, err := http.ParseTime(resp.Header.Get(FetchedHeader)); err == nil {
+		entry.Fetched = fetched
+	}
+	if expires, err := http.ParseTime(resp.Header.Get(ExpiresHeader)); err == nil {
+		entry.Expires = expires
+	}
+	return entry
+}
+
+func (fc FileCacher) GetContext(ctx context.Context, request *http.Request) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fc.Get(request)
+}
+
+func (fc FileCacher) SaveContext(ctx context.Context, request *http.Request, response *http.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fc.Save(request, response)
+}
+
+//Delete removes the entry and the directories it leaves empty below Dir
+func (fc FileCacher) Delete(ctx context.Context, key string) error {
+	request, err := keyRequest(ctx, key)
+	if err != nil {
+		return err
+	}
+	ioer, ok := fc.fileCacherIoV2()
+	if !ok {
+		return ErrCacherUnsupported
+	}
+	dir, fn := fc.FilePath(request)
+	if err := ioer.Remove(filepath.Join(dir, fn)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	//best effort, directories holding other entries are not empty and stay
+	for dir != fc.Dir && strings.HasPrefix(dir, fc.Dir) {
+		if ioer.Remove(dir) != nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return nil
+}
+
+func (fc FileCacher) Stat(ctx context.Context, key string) (CacheEntry, error) {
+	request, err := keyRequest(ctx, key)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	dir, fn := fc.FilePath(request)
+	return fc.stat(filepath.Join(dir, fn), key)
+}
+
+//stat fills what the file itself tells when the entry cannot be decoded, together with the error
+func (fc FileCacher) stat(path string, key string) (CacheEntry, error) {
+	var info os.FileInfo
+	if ioer, ok := fc.fileCacherIoV2(); ok {
+		var err error
+		info, err = ioer.Stat(path)
+		if os.IsNotExist(err) {
+			return CacheEntry{}, &NotCachedError{Key: key}
+		}
+		if err != nil {
+			return CacheEntry{}, err
+		}
+	}
+	f, err := fc.FileCacherIo.Read(path)
+	if os.IsNotExist(err) {
+		return CacheEntry{}, &NotCachedError{Key: key}
+	}
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	defer f.Close()
+
+	entry := CacheEntry{Key: key}
+	entry.Endpoint, entry.Ticker = KeyEndpoint(key)
+	resp, _, err := DecodeCacheEntry(f, nil)
+	if err == nil {
+		resp.Body.Close()
+		entry = NewCacheEntry(key, resp)
+	}
+	if info != nil {
+		entry.Size = info.Size()
+		if entry.Fetched.IsZero() {
+			entry.Fetched = info.ModTime()
+		}
+	}
+	return entry, err
+}
+
+//Walk visits entries in lexical path order, entries that cannot be decoded are visited with what the file tells
+func (fc FileCacher) Walk(ctx context.Context, fn func(entry CacheEntry) error) error {
+	ioer, ok := fc.fileCacherIoV2()
+	if !ok {
+		return ErrCacherUnsupported
+	}
+	return ioer.Walk(fc.Dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == fc.Dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		key, err := fc.Key(path)
+		if err != nil {
+			return err
+		}
+		entry, err := fc.stat(path, key)
+		if entry.Key == "" {
+			return err
+		}
+		return fn(entry)
+	})
+}
@@ -0,0 +1,242 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileCacher_CacherV2(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "cacherv2-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 14, 0, 0, 0, time.UTC)}
+	fc := FileCacher{Dir: dir, FileCacherIo: OsFileCacherIo{}, Clock: clock}
+	aapl, _ := http.NewRequest("GET", "http://base/v2/ticks/stocks/nbbo/AAPL/2020-04-24?apiKey=secret&limit=0", nil)
+	msft, _ := http.NewRequest("GET", "http://base/v2/aggs/ticker/MSFT/range/1/minute/2020-04-23/2020-04-23?apiKey=secret&unadjusted=false", nil)
+
+	live := LiveFreshness(clock.now, time.Minute).stamped(cachedResponse(aapl, "aapl"))
+	if err := fc.SaveContext(ctx, aapl, live); err != nil {
+		t.Fatal(err)
+	}
+	if live.Header.Get(FetchedHeader) != "" {
+		t.Errorf("Save() stamped the caller's response with %v", FetchedHeader)
+	}
+	if err := fc.SaveContext(ctx, msft, cachedResponse(msft, "msft")); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := fc.Stat(ctx, RequestKey(aapl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CacheEntry{Key: RequestKey(aapl), Endpoint: "nbbo", Ticker: "AAPL", Size: entry.Size, Fetched: clock.now, Expires: clock.now.Add(time.Minute)}
+	if entry != want || entry.Size == 0 {
+		t.Errorf("Stat() = %+v, want %+v", entry, want)
+	}
+	if entry.TTL() != time.Minute {
+		t.Errorf("TTL() = %v", entry.TTL())
+	}
+
+	keys := []string{}
+	if err := fc.Walk(ctx, func(entry CacheEntry) error {
+		keys = append(keys, entry.Key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("Walk() visited %v", keys)
+	}
+
+	if err := fc.Delete(ctx, RequestKey(aapl)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.Stat(ctx, RequestKey(aapl)); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Stat() after Delete() err = %v, want ErrNotCached", err)
+	}
+	if err := fc.Delete(ctx, RequestKey(aapl)); err != nil {
+		t.Errorf("Delete() of a missing key = %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if resp, err := fc.GetContext(cancelled, msft); resp != nil || err != context.Canceled {
+		t.Errorf("GetContext() = %v, %v with a cancelled context", resp, err)
+	}
+}
+
+//movedIo stores below to what the FileCacher asks for below from
+type movedIo struct {
+	OsFileCacherIo
+	from string
+	to   string
+}
+
+func (mi movedIo) move(path string) string {
+	return filepath.Join(mi.to, strings.TrimPrefix(path, mi.from))
+}
+
+func (mi movedIo) AtomicWrite(dir string, filename string, write func(w io.Writer) error) error {
+	return mi.OsFileCacherIo.AtomicWrite(mi.move(dir), filename, write)
+}
+
+func (mi movedIo) Read(path string) (io.ReadCloser, error) {
+	return mi.OsFileCacherIo.Read(mi.move(path))
+}
+
+func (mi movedIo) Remove(path string) error {
+	return mi.OsFileCacherIo.Remove(mi.move(path))
+}
+
+func (mi movedIo) Stat(path string) (os.FileInfo, error) {
+	return mi.OsFileCacherIo.Stat(mi.move(path))
+}
+
+func (mi movedIo) Walk(root string, fn filepath.WalkFunc) error {
+	return mi.OsFileCacherIo.Walk(mi.move(root), func(path string, info os.FileInfo, err error) error {
+		return fn(filepath.Join(mi.from, strings.TrimPrefix(path, mi.to)), info, err)
+	})
+}
+
+func TestFileCacher_CacherV2ThroughIo(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "cacherv2-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	//nothing exists at Dir, every operation has to go through the FileCacherIo to find the entry
+	fc := FileCacher{Dir: filepath.Join(dir, "missing"), FileCacherIo: movedIo{from: filepath.Join(dir, "missing"), to: filepath.Join(dir, "stored")}}
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL?apiKey=secret", nil)
+	if err := fc.SaveContext(ctx, r, cachedResponse(r, "aapl")); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, err := fc.Stat(ctx, RequestKey(r)); err != nil || entry.Size == 0 {
+		t.Errorf("Stat() = %+v, %v", entry, err)
+	}
+	keys := []string{}
+	if err := fc.Walk(ctx, func(entry CacheEntry) error {
+		keys = append(keys, entry.Key)
+		return nil
+	}); err != nil || len(keys) != 1 || keys[0] != RequestKey(r) {
+		t.Errorf("Walk() visited %v, %v", keys, err)
+	}
+	if err := fc.Delete(ctx, RequestKey(r)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.Stat(ctx, RequestKey(r)); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Stat() after Delete() err = %v, want ErrNotCached", err)
+	}
+}
+
+//plainIo is a FileCacherIo that is not a FileCacherIoV2
+type plainIo struct {
+	io OsFileCacherIo
+}
+
+func (pi plainIo) AtomicWrite(dir string, filename string, write func(w io.Writer) error) error {
+	return pi.io.AtomicWrite(dir, filename, write)
+}
+
+func (pi plainIo) Read(path string) (io.ReadCloser, error) {
+	return pi.io.Read(path)
+}
+
+func TestFileCacher_CacherV2WithoutIoV2(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "cacherv2-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	fc := FileCacher{Dir: dir, FileCacherIo: plainIo{}}
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL?apiKey=secret", nil)
+	if err := fc.SaveContext(ctx, r, cachedResponse(r, "aapl")); err != nil {
+		t.Fatal(err)
+	}
+
+	//read through the FileCacherIo, which cannot tell the stored size
+	if entry, err := fc.Stat(ctx, RequestKey(r)); err != nil || entry.Key != RequestKey(r) || entry.Size != 0 {
+		t.Errorf("Stat() = %+v, %v", entry, err)
+	}
+	if err := fc.Walk(ctx, func(entry CacheEntry) error { return nil }); err != ErrCacherUnsupported {
+		t.Errorf("Walk() = %v, want ErrCacherUnsupported", err)
+	}
+	if err := fc.Delete(ctx, RequestKey(r)); err != ErrCacherUnsupported {
+		t.Errorf("Delete() = %v, want ErrCacherUnsupported", err)
+	}
+	//nothing was touched on disk behind the FileCacherIo's back
+	dirPath, fn := fc.FilePath(r)
+	if _, err := os.Stat(filepath.Join(dirPath, fn)); err != nil {
+		t.Errorf("entry gone after an unsupported Delete(), %v", err)
+	}
+}
+
+func TestCacherV2From(t *testing.T) {
+
+	ctx := context.Background()
+	if _, ok := CacherV2From(FileCacher{}).(FileCacher); !ok {
+		t.Error("FileCacher was wrapped")
+	}
+	if CacherV2From(nil) != nil {
+		t.Error("nil was wrapped")
+	}
+
+	adapted := CacherV2From(&staticCacher{})
+	if err := adapted.Delete(ctx, "http://base/?apiKey=X"); err != ErrCacherUnsupported {
+		t.Errorf("Delete() = %v", err)
+	}
+	if err := adapted.Walk(ctx, func(CacheEntry) error { return nil }); err != ErrCacherUnsupported {
+		t.Errorf("Walk() = %v", err)
+	}
+
+	mc := NewMemoryCacher(0, 0)
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL?apiKey=secret", nil)
+	mc.Save(r, cachedResponse(r, "aapl"))
+	//only the Cacher methods, so it goes through the adapter
+	entry, err := CacherV2From(struct{ Cacher }{mc}).Stat(ctx, RequestKey(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the adapter cannot tell the stored size
+	if entry.Endpoint != "last_quote" || entry.Ticker != "AAPL" || entry.Size != 0 {
+		t.Errorf("Stat() = %+v", entry)
+	}
+}
+
+func TestKeyEndpoint(t *testing.T) {
+	tests := []struct {
+		key          string
+		wantEndpoint string
+		wantTicker   string
+	}{
+		{key: "https://api.polygon.io/v2/aggs/ticker/AAPL/range/1/day/2019-01-01/2019-01-02?apiKey=X", wantEndpoint: "aggregates", wantTicker: "AAPL"},
+		{key: "https://api.polygon.io/v2/ticks/stocks/trades/TSLA/2018-02-02?apiKey=X&limit=0", wantEndpoint: "trades", wantTicker: "TSLA"},
+		{key: "https://api.polygon.io/v1/last_quote/stocks/MSFT?apiKey=X", wantEndpoint: "last_quote", wantTicker: "MSFT"},
+		{key: "https://api.polygon.io/v1/meta?apiKey=X", wantEndpoint: "unknown"},
+	}
+	for _, tt := range tests {
+		endpoint, ticker := KeyEndpoint(tt.key)
+		if endpoint != tt.wantEndpoint || ticker != tt.wantTicker {
+			t.Errorf("KeyEndpoint(%v) = %v, %v, want %v, %v", tt.key, endpoint, ticker, tt.wantEndpoint, tt.wantTicker)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+//Package calendar knows the trading days and session times of US equity exchanges (NYSE and Nasdaq share them).
+//Every date is a day in AmericaNewYork, the time of day of a date argument does not matter
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var AmericaNewYork *time.Location = nil
+
+func init() {
+	anyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		panic(err)
+	}
+	AmericaNewYork = anyc
+
+	for day, name := range specialClosures {
+		d, err := time.ParseInLocation("2006-01-02", day, AmericaNewYork)
+		if err != nil {
+			panic(err)
+		}
+		specialDays = append(specialDays, Holiday{d, name})
+	}
+}
+
+type Holiday struct {
+	//midnight in AmericaNewYork of the day the market is closed
+	Date time.Time
+	Name string
+}
+
+//closures that no rule predicts
+var specialClosures = map[string]string{
+	"2001-09-11": "September 11",
+	"2001-09-12": "September 11",
+	"2001-09-13": "September 11",
+	"2001-09-14": "September 11",
+	"2004-06-11": "Reagan national day of mourning",
+	"2007-01-02": "Ford national day of mourning",
+	"2012-10-29": "Hurricane Sandy",
+	"2012-10-30": "Hurricane Sandy",
+	"2018-12-05": "Bush national day of mourning",
+	"2025-01-09": "Carter national day of mourning",
+}
+
+//specialClosures parsed once
+var specialDays []Holiday
+
+//ErrNoSession is returned when no trading day is found within maxGap days, the rules never leave such a gap
+var ErrNoSession = errors.New("no trading day within a month")
+
+//holidays of a year, built once per year and read by every IsHoliday
+type yearHolidays struct {
+	list []Holiday
+	//by day of the year
+	names map[int]string
+}
+
+var (
+	holidaysMu sync.Mutex
+	years      = map[int]*yearHolidays{}
+)
+
+//Day is midnight in AmericaNewYork of the day t falls on there
+func Day(t time.Time) time.Time {
+	t = t.In(AmericaNewYork)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, AmericaNewYork)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, AmericaNewYork)
+}
+
+//nthWeekday is the nth weekday of month, n < 0 counts from the end
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	if n < 0 {
+		last := date(year, month+1, 0)
+		return last.AddDate(0, 0, -((int(last.Weekday())-int(weekday)+7)%7 + 7*(-n-1)))
+	}
+	first := date(year, month, 1)
+	return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(n-1))
+}
+
+//observed moves a saturday holiday to friday and a sunday holiday to monday
+func observed(day time.Time) time.Time {
+	switch day.Weekday() {
+	case time.Saturday:
+		return day.AddDate(0, 0, -1)
+	case time.Sunday:
+		return day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+//easter is easter sunday, anonymous gregorian algorithm
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day)
+}
+
+//Holidays are the full day closures of year in date order
+func Holidays(year int) []Holiday {
+	return append([]Holiday(nil), holidaysOf(year).list...)
+}
+
+func holidaysOf(year int) *yearHolidays {
+	holidaysMu.Lock()
+	defer holidaysMu.Unlock()
+	if yh, ok := years[year]; ok {
+		return yh
+	}
+	yh := &yearHolidays{list: ruleHolidays(year), names: map[int]string{}}
+	for _, h := range yh.list {
+		yh.names[h.Date.YearDay()] = h.Name
+	}
+	years[year] = yh
+	return yh
+}
+
+func ruleHolidays(year int) []Holiday {
+	out := []Holiday{}
+	//a saturday new year is not observed on the friday before, that friday is in the previous year
+	if newYear := date(year, time.January, 1); newYear.Weekday() != time.Saturday {
+		out = append(out, Holiday{observed(newYear), "New Year's Day"})
+	}
+	if year >= 1998 {
+		out = append(out, Holiday{nthWeekday(year, time.January, time.Monday, 3), "Martin Luther King Jr. Day"})
+	}
+	out = append(out,
+		Holiday{nthWeekday(year, time.February, time.Monday, 3), "Washington's Birthday"},
+		Holiday{easter(year).AddDate(0, 0, -2), "Good Friday"},
+		Holiday{nthWeekday(year, time.May, time.Monday, -1), "Memorial Day"},
+	)
+	if year >= 2022 {
+		out = append(out, Holiday{observed(date(year, time.June, 19)), "Juneteenth"})
+	}
+	out = append(out,
+		Holiday{observed(date(year, time.July, 4)), "Independence Day"},
+		Holiday{nthWeekday(year, time.September, time.Monday, 1), "Labor Day"},
+		Holiday{nthWeekday(year, time.November, time.Thursday, 4), "Thanksgiving Day"},
+		Holiday{observed(date(year, time.December, 25)), "Christmas Day"},
+	)
+	for _, h := range specialDays {
+		if h.Date.Year() == year {
+			out = append(out, h)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+	return out
+}
+
+func IsHoliday(day time.Time) bool {
+	_, ok := HolidayName(day)
+	return ok
+}
+
+func HolidayName(day time.Time) (string, bool) {
+	day = Day(day)
+	name, ok := holidaysOf(day.Year()).names[day.YearDay()]
+	return name, ok
+}
+
+func IsTradingDay(day time.Time) bool {
+	day = Day(day)
+	switch day.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return !IsHoliday(day)
+}
+
+//IsHalfDay is true for trading days the regular session closes at 1pm: the day before Independence Day,
+//the day after Thanksgiving and Christmas Eve
+func IsHalfDay(day time.Time) bool {
+	day = Day(day)
+	if !IsTradingDay(day) {
+		return false
+	}
+	year := day.Year()
+	switch {
+	//a friday july 3rd is the observed holiday, a saturday or sunday 4th has no early close
+	case day.Equal(date(year, time.July, 3)) && day.Weekday() <= time.Thursday:
+		return true
+	case day.Equal(nthWeekday(year, time.November, time.Thursday, 4).AddDate(0, 0, 1)):
+		return true
+	case day.Equal(date(year, time.December, 24)) && day.Weekday() <= time.Thursday:
+		return true
+	}
+	return false
+}
+
+type Session int
+
+const (
+	Closed Session = iota
+	PreMarket
+	Regular
+	AfterHours
+)
+
+func (s Session) String() string {
+	switch s {
+	case PreMarket:
+		return "pre-market"
+	case Regular:
+		return "regular"
+	case AfterHours:
+		return "after-hours"
+	}
+	return "closed"
+}
+
+//Bounds are the session times of one trading day, pre-market runs from PreOpen to Open,
+//the regular session from Open to Close and after-hours from Close to PostClose
+type Bounds struct {
+	//midnight of the trading day
+	Date      time.Time
+	PreOpen   time.Time
+	Open      time.Time
+	Close     time.Time
+	PostClose time.Time
+}
+
+//Session tells which session t falls in, Closed outside of PreOpen to PostClose
+func (b Bounds) Session(t time.Time) Session {
+	switch {
+	case t.Before(b.PreOpen) || !t.Before(b.PostClose):
+		return Closed
+	case t.Before(b.Open):
+		return PreMarket
+	case t.Before(b.Close):
+		return Regular
+	}
+	return AfterHours
+}
+
+//SessionBounds are the session times of day, false when it is not a trading day
+func SessionBounds(day time.Time) (Bounds, bool) {
+	day = Day(day)
+	if !IsTradingDay(day) {
+		return Bounds{}, false
+	}
+	at := func(hour int, min int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, AmericaNewYork)
+	}
+	b := Bounds{Date: day, PreOpen: at(4, 0), Open: at(9, 30), Close: at(16, 0), PostClose: at(20, 0)}
+	if IsHalfDay(day) {
+		b.Close, b.PostClose = at(13, 0), at(17, 0)
+	}
+	return b, true
+}
+
+//trading days are never more than a couple of weeks apart
+const maxGap = 31
+
+//NextSession is the session of the first trading day after the day of t
+func NextSession(t time.Time) (Bounds, error) {
+	day := Day(t)
+	for i := 0; i < maxGap; i++ {
+		day = day.AddDate(0, 0, 1)
+		if b, ok := SessionBounds(day); ok {
+			return b, nil
+		}
+	}
+	return Bounds{}, fmt.Errorf("after %s: %w", t.Format("2006-01-02"), ErrNoSession)
+}
+
+//PrevSession is the session of the last trading day before the day of t
+func PrevSession(t time.Time) (Bounds, error) {
+	day := Day(t)
+	for i := 0; i < maxGap; i++ {
+		day = day.AddDate(0, 0, -1)
+		if b, ok := SessionBounds(day); ok {
+			return b, nil
+		}
+	}
+	return Bounds{}, fmt.Errorf("before %s: %w", t.Format("2006-01-02"), ErrNoSession)
+}
+
+//SessionOf is the session t belongs to, the last one whose pre-market opened at or before t
+func SessionOf(t time.Time) (Bounds, error) {
+	if b, ok := SessionBounds(t); ok && !t.Before(b.PreOpen) {
+		return b, nil
+	}
+	return PrevSession(t)
+}
+
+//TradingDaysBetween counts the trading days from the day of from to the day of to, both included
+func TradingDaysBetween(from time.Time, to time.Time) int {
+	count := 0
+	for day, end := Day(from), Day(to); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if IsTradingDay(day) {
+			count++
+		}
+	}
+	return count
+}
+
+//USEquities is the calendar as a value, it satisfies polygonio.TradingCalendar
+type USEquities struct{}
+
+func (USEquities) IsTradingDay(day time.Time) bool {
+	return IsTradingDay(day)
+}
+
+func (USEquities) SessionBounds(day time.Time) (Bounds, bool) {
+	return SessionBounds(day)
+}
@@ -0,0 +1,144 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func ny(year int, month time.Month, day int, hour int, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, AmericaNewYork)
+}
+
+func TestHolidays(t *testing.T) {
+	tests := []struct {
+		year int
+		want []string
+	}{
+		//christmas and new year on saturday, both observed the friday before except new year
+		{year: 2021, want: []string{"2021-01-01", "2021-01-18", "2021-02-15", "2021-04-02", "2021-05-31", "2021-07-05", "2021-09-06", "2021-11-25", "2021-12-24"}},
+		//new year 2022 on a saturday is not observed, juneteenth observed monday
+		{year: 2022, want: []string{"2022-01-17", "2022-02-21", "2022-04-15", "2022-05-30", "2022-06-20", "2022-07-04", "2022-09-05", "2022-11-24", "2022-12-26"}},
+		{year: 2023, want: []string{"2023-01-02", "2023-01-16", "2023-02-20", "2023-04-07", "2023-05-29", "2023-06-19", "2023-07-04", "2023-09-04", "2023-11-23", "2023-12-25"}},
+		{year: 2025, want: []string{"2025-01-01", "2025-01-09", "2025-01-20", "2025-02-17", "2025-04-18", "2025-05-26", "2025-06-19", "2025-07-04", "2025-09-01", "2025-11-27", "2025-12-25"}},
+		{year: 2012, want: []string{"2012-01-02", "2012-01-16", "2012-02-20", "2012-04-06", "2012-05-28", "2012-07-04", "2012-09-03", "2012-10-29", "2012-10-30", "2012-11-22", "2012-12-25"}},
+	}
+	for _, tt := range tests {
+		got := Holidays(tt.year)
+		if len(got) != len(tt.want) {
+			t.Errorf("Holidays(%v) = %v, want %v", tt.year, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if day := got[i].Date.Format("2006-01-02"); day != tt.want[i] {
+				t.Errorf("Holidays(%v)[%v] = %v %v, want %v", tt.year, i, day, got[i].Name, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestTradingDaysBetween(t *testing.T) {
+	for year, want := range map[int]int{2019: 252, 2020: 253, 2021: 252, 2022: 251, 2023: 250, 2024: 252, 2025: 250} {
+		if got := TradingDaysBetween(ny(year, 1, 1, 0, 0), ny(year, 12, 31, 23, 0)); got != want {
+			t.Errorf("TradingDaysBetween(%v) = %v, want %v", year, got, want)
+		}
+	}
+}
+
+func TestSessionBounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		day       time.Time
+		wantOk    bool
+		wantClose time.Time
+		wantPost  time.Time
+	}{
+		{name: "regular", day: ny(2020, 4, 24, 12, 0), wantOk: true, wantClose: ny(2020, 4, 24, 16, 0), wantPost: ny(2020, 4, 24, 20, 0)},
+		{name: "day after thanksgiving", day: ny(2020, 11, 27, 0, 0), wantOk: true, wantClose: ny(2020, 11, 27, 13, 0), wantPost: ny(2020, 11, 27, 17, 0)},
+		{name: "christmas eve", day: ny(2020, 12, 24, 0, 0), wantOk: true, wantClose: ny(2020, 12, 24, 13, 0), wantPost: ny(2020, 12, 24, 17, 0)},
+		{name: "july 3rd on a monday", day: ny(2023, 7, 3, 0, 0), wantOk: true, wantClose: ny(2023, 7, 3, 13, 0), wantPost: ny(2023, 7, 3, 17, 0)},
+		{name: "july 3rd on a friday is the holiday", day: ny(2020, 7, 3, 0, 0)},
+		{name: "friday before a saturday christmas eve", day: ny(2022, 12, 23, 0, 0), wantOk: true, wantClose: ny(2022, 12, 23, 16, 0), wantPost: ny(2022, 12, 23, 20, 0)},
+		{name: "weekend", day: ny(2020, 4, 25, 12, 0)},
+		{name: "good friday", day: ny(2020, 4, 10, 12, 0)},
+		//saturday 01:00 utc is still friday in new york
+		{name: "utc", day: time.Date(2020, 4, 25, 1, 0, 0, 0, time.UTC), wantOk: true, wantClose: ny(2020, 4, 24, 16, 0), wantPost: ny(2020, 4, 24, 20, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SessionBounds(tt.day)
+			if ok != tt.wantOk {
+				t.Fatalf("SessionBounds() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if !got.Close.Equal(tt.wantClose) || !got.PostClose.Equal(tt.wantPost) {
+				t.Errorf("SessionBounds() = %v, want close %v post %v", got, tt.wantClose, tt.wantPost)
+			}
+			if got.Open.In(AmericaNewYork).Hour() != 9 || got.PreOpen.In(AmericaNewYork).Hour() != 4 {
+				t.Errorf("SessionBounds() = %v, want pre-market at 4 and open at 9:30", got)
+			}
+		})
+	}
+}
+
+func TestBounds_Session(t *testing.T) {
+	//first trading day after spring forward
+	b, _ := SessionBounds(ny(2020, 3, 9, 0, 0))
+	tests := []struct {
+		at   time.Time
+		want Session
+	}{
+		{at: ny(2020, 3, 9, 3, 59), want: Closed},
+		{at: ny(2020, 3, 9, 4, 0), want: PreMarket},
+		{at: ny(2020, 3, 9, 9, 29), want: PreMarket},
+		{at: time.Date(2020, 3, 9, 13, 30, 0, 0, time.UTC), want: Regular},
+		{at: ny(2020, 3, 9, 15, 59), want: Regular},
+		{at: ny(2020, 3, 9, 16, 0), want: AfterHours},
+		{at: ny(2020, 3, 9, 20, 0), want: Closed},
+	}
+	for _, tt := range tests {
+		if got := b.Session(tt.at); got != tt.want {
+			t.Errorf("Session(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestNextPrevSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantNext time.Time
+		wantPrev time.Time
+		wantOf   time.Time
+	}{
+		{name: "good friday weekend", at: ny(2020, 4, 10, 12, 0), wantNext: ny(2020, 4, 13, 0, 0), wantPrev: ny(2020, 4, 9, 0, 0), wantOf: ny(2020, 4, 9, 0, 0)},
+		{name: "before pre-market", at: ny(2020, 4, 14, 3, 0), wantNext: ny(2020, 4, 15, 0, 0), wantPrev: ny(2020, 4, 13, 0, 0), wantOf: ny(2020, 4, 13, 0, 0)},
+		{name: "after hours", at: ny(2020, 4, 14, 19, 0), wantNext: ny(2020, 4, 15, 0, 0), wantPrev: ny(2020, 4, 13, 0, 0), wantOf: ny(2020, 4, 14, 0, 0)},
+		{name: "christmas into new year", at: ny(2020, 12, 31, 12, 0), wantNext: ny(2021, 1, 4, 0, 0), wantPrev: ny(2020, 12, 30, 0, 0), wantOf: ny(2020, 12, 31, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := NextSession(tt.at); err != nil || !got.Date.Equal(tt.wantNext) {
+				t.Errorf("NextSession() = %v, %v, want %v", got.Date, err, tt.wantNext)
+			}
+			if got, err := PrevSession(tt.at); err != nil || !got.Date.Equal(tt.wantPrev) {
+				t.Errorf("PrevSession() = %v, %v, want %v", got.Date, err, tt.wantPrev)
+			}
+			if got, err := SessionOf(tt.at); err != nil || !got.Date.Equal(tt.wantOf) {
+				t.Errorf("SessionOf() = %v, %v, want %v", got.Date, err, tt.wantOf)
+			}
+		})
+	}
+}
+
+func TestHolidays_Cached(t *testing.T) {
+	got := Holidays(2020)
+	got[0].Name = "changed"
+	if name, _ := HolidayName(got[0].Date); name != "New Year's Day" {
+		t.Errorf("changing the returned slice changed the calendar, HolidayName() = %v", name)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { IsHoliday(ny(2020, 4, 10, 0, 0)) }); allocs > 0 {
+		t.Errorf("IsHoliday() allocates %v times, holidays are rebuilt", allocs)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+)
+
+var (
+	olderThan = flag.Duration("older-than", 0, "cache prune: remove entries not written for this long")
+	endpoint  = flag.String("endpoint", "", "cache prune: only entries of this endpoint (aggregates, nbbo, trades, last_quote)")
+	maxBytes  = flag.Int64("max-bytes", 0, "cache prune: remove the oldest entries until the cache fits")
+	dryRun    = flag.Bool("dry-run", false, "cache prune/import/migrate: only print what would change")
+	encoding  = flag.String("encoding", "raw", "cache encoding for new entries and cache migrate: raw or gzip")
+	dropHdrs  = flag.Bool("drop-headers", false, "drop volatile response headers from new entries and on cache migrate")
+)
+
+type cacheEntry struct {
+	Key string
+	//relative to the cache dir
+	Path     string
+	Endpoint string
+	Ticker   string
+	Size     int64
+	Fetched  time.Time
+}
+
+//fileCacher is the cache of PolygonClient, the cache commands work on what it stores
+func fileCacher() polygonio.FileCacher {
+	return PolygonClient().Cacher.(polygonio.FileCacher)
+}
+
+//entryPath is where fc stores the entry of key
+func entryPath(fc polygonio.FileCacher, key string) (string, error) {
+	r, err := http.NewRequest("GET", key, nil)
+	if err != nil {
+		return "", err
+	}
+	dir, fn := fc.FilePath(r)
+	return filepath.Join(dir, fn), nil
+}
+
+//readEntry returns the stored bytes of the entry at path
+func readEntry(fc polygonio.FileCacher, path string) ([]byte, error) {
+	f, err := fc.FileCacherIo.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func walkCache(fc polygonio.FileCacher) ([]cacheEntry, error) {
+	out := []cacheEntry{}
+	err := fc.Walk(context.Background(), func(ce polygonio.CacheEntry) error {
+		path, err := entryPath(fc, ce.Key)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(fc.Dir, path)
+		if err != nil {
+			return err
+		}
+		out = append(out, cacheEntry{Key: ce.Key, Path: rel, Endpoint: ce.Endpoint, Ticker: ce.Ticker, Size: ce.Size, Fetched: ce.Fetched})
+		return nil
+	})
+	return out, err
+}
+
+func cmdCache() {
+	fc := fileCacher()
+	var err error
+	switch flag.Arg(0) {
+	case "stats":
+		err = cacheStats(fc, os.Stdout)
+	case "prune":
+		err = cachePrune(fc, os.Stdout)
+	case "verify":
+		err = cacheVerify(fc, os.Stdout)
+	case "export":
+		err = cacheExport(fc, flag.Arg(1))
+	case "import":
+		err = cacheImport(fc, flag.Arg(1), os.Stdout)
+	case "migrate":
+		err = cacheMigrate(fc, os.Stdout)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: -cmd cache [flags] stats|prune|verify|migrate|export <archive.tar>|import <archive.tar>")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func cacheStats(fc polygonio.FileCacher, w io.Writer) error {
+	entries, err := walkCache(fc)
+	if err != nil {
+		return err
+	}
+
+	type tally struct {
+		entries int
+		bytes   int64
+	}
+	total := tally{}
+	byEndpoint := map[string]*tally{}
+	byTicker := map[string]*tally{}
+	add := func(m map[string]*tally, key string, e cacheEntry) {
+		if m[key] == nil {
+			m[key] = &tally{}
+		}
+		m[key].entries++
+		m[key].bytes += e.Size
+	}
+	for _, e := range entries {
+		total.entries++
+		total.bytes += e.Size
+		add(byEndpoint, e.Endpoint, e)
+		if e.Ticker != "" {
+			add(byTicker, e.Ticker, e)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	section := func(title string, m map[string]*tally) {
+		keys := []string{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(tw, "\n%s\tentries\tbytes\n", title)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%d\t%d\n", k, m[k].entries, m[k].bytes)
+		}
+	}
+	fmt.Fprintf(tw, "%s\tentries\tbytes\n", fc.Dir)
+	fmt.Fprintf(tw, "total\t%d\t%d\n", total.entries, total.bytes)
+	section("endpoint", byEndpoint)
+	section("ticker", byTicker)
+	return tw.Flush()
+}
+
+//pruneSelect picks the entries to remove, filters narrow the candidates and
+//the size budget then removes the oldest candidates until the whole cache fits
+func pruneSelect(entries []cacheEntry, now time.Time, olderThan time.Duration, ticker string, endpoint string, maxBytes int64) []cacheEntry {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Fetched.Before(entries[j].Fetched) })
+
+	total := int64(0)
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	out := []cacheEntry{}
+	for _, e := range entries {
+		if ticker != "" && e.Ticker != ticker {
+			continue
+		}
+		if endpoint != "" && e.Endpoint != endpoint {
+			continue
+		}
+		switch {
+		case olderThan > 0 && now.Sub(e.Fetched) >= olderThan:
+		case maxBytes > 0 && total > maxBytes:
+		case olderThan == 0 && maxBytes == 0:
+		default:
+			continue
+		}
+		out = append(out, e)
+		total -= e.Size
+	}
+	return out
+}
+
+func cachePrune(fc polygonio.FileCacher, w io.Writer) error {
+	entries, err := walkCache(fc)
+	if err != nil {
+		return err
+	}
+	if *olderThan == 0 && *maxBytes == 0 && *ticker == "" && *endpoint == "" {
+		return fmt.Errorf("refusing to prune everything, set -older-than, -max-bytes, -ticker or -endpoint")
+	}
+
+	removed := int64(0)
+	for _, e := range pruneSelect(entries, time.Now(), *olderThan, *ticker, *endpoint, *maxBytes) {
+		fmt.Fprintln(w, "remove", e.Path)
+		removed += e.Size
+		if *dryRun {
+			continue
+		}
+		if err := fc.Delete(context.Background(), e.Key); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "removed %d bytes\n", removed)
+	return nil
+}
+
+func containerFor(endpoint string) interface{} {
+	switch endpoint {
+	case "aggregates":
+		return &polygonio.AggregatesResponseContainer{}
+	case "nbbo":
+		return &polygonio.HistoricQuotesResponseContainer{}
+	case "trades":
+		return &polygonio.HistoricTradesResponseContainer{}
+	case "last_quote":
+		return &polygonio.LastQuoteResponseContainer{}
+	}
+	return &map[string]interface{}{}
+}
+
+func verifyEntry(fc polygonio.FileCacher, path string, endpoint string) error {
+	stored, err := readEntry(fc, path)
+	if err != nil {
+		return err
+	}
+
+	resp, _, err := polygonio.DecodeCacheEntry(bytes.NewReader(stored), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("stored status %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, containerFor(endpoint))
+}
+
+func cacheVerify(fc polygonio.FileCacher, w io.Writer) error {
+	entries, err := walkCache(fc)
+	if err != nil {
+		return err
+	}
+	corrupt := 0
+	for _, e := range entries {
+		if err := verifyEntry(fc, filepath.Join(fc.Dir, e.Path), e.Endpoint); err != nil {
+			corrupt++
+			fmt.Fprintf(w, "corrupt %s: %v\n", e.Path, err)
+		}
+	}
+	fmt.Fprintf(w, "%d entries, %d corrupt\n", len(entries), corrupt)
+	if corrupt > 0 {
+		return fmt.Errorf("%d corrupt entries", corrupt)
+	}
+	return nil
+}
+
+func cacheExport(fc polygonio.FileCacher, archive string) error {
+	if archive == "" {
+		return fmt.Errorf("export needs an archive path")
+	}
+	entries, err := walkCache(fc)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, e := range entries {
+		stored, err := readEntry(fc, filepath.Join(fc.Dir, e.Path))
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    filepath.ToSlash(e.Path),
+			Mode:    0644,
+			Size:    int64(len(stored)),
+			ModTime: e.Fetched,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(stored); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func cacheImport(fc polygonio.FileCacher, archive string, w io.Writer) error {
+	if archive == "" {
+		return fmt.Errorf("import needs an archive path")
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	count := 0
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		rel := filepath.FromSlash(h.Name)
+		if filepath.IsAbs(rel) || strings.HasPrefix(filepath.Clean(rel), "..") {
+			return fmt.Errorf("refusing to import %s outside the cache", h.Name)
+		}
+
+		count++
+		if *dryRun {
+			fmt.Fprintln(w, "import", rel)
+			continue
+		}
+		target := filepath.Join(fc.Dir, rel)
+		if err := fc.FileCacherIo.AtomicWrite(filepath.Dir(target), filepath.Base(target), func(out io.Writer) error {
+			_, err := io.Copy(out, tr)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "imported %d entries\n", count)
+	return nil
+}
+
+//cacheMigrate rewrites every entry in place with -encoding and -drop-headers
+func cacheMigrate(fc polygonio.FileCacher, w io.Writer) error {
+	target, err := polygonio.ParseCacheEncoding(*encoding)
+	if err != nil {
+		return err
+	}
+	entries, err := walkCache(fc)
+	if err != nil {
+		return err
+	}
+
+	migrated, before, after := 0, int64(0), int64(0)
+	for _, e := range entries {
+		path := filepath.Join(fc.Dir, e.Path)
+		stored, err := readEntry(fc, path)
+		if err != nil {
+			return err
+		}
+		resp, current, err := polygonio.DecodeCacheEntry(bytes.NewReader(stored), nil)
+		if err != nil {
+			fmt.Fprintf(w, "skip corrupt %s: %v\n", e.Path, err)
+			continue
+		}
+		if current == target && !*dropHdrs {
+			resp.Body.Close()
+			continue
+		}
+
+		migrated++
+		before += e.Size
+		if *dryRun {
+			fmt.Fprintf(w, "migrate %s %s -> %s\n", e.Path, current, target)
+			resp.Body.Close()
+			continue
+		}
+		err = fc.FileCacherIo.AtomicWrite(filepath.Dir(path), filepath.Base(path), func(out io.Writer) error {
+			return polygonio.EncodeCacheEntry(out, resp, target, *dropHdrs)
+		})
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if entry, err := fc.Stat(context.Background(), e.Key); err == nil {
+			after += entry.Size
+		}
+	}
+	fmt.Fprintf(w, "migrated %d of %d entries, %d -> %d bytes\n", migrated, len(entries), before, after)
+	return nil
+}
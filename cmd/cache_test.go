@@ -0,0 +1,432 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+)
+
+//seedEntry is a response stored by a FileCacher
+type seedEntry struct {
+	url      string
+	status   int
+	body     string
+	encoding polygonio.CacheEncoding
+}
+
+var aaplAggregates = "https://api.polygon.io/v2/aggs/ticker/AAPL/range/1/day/2019-01-01/2019-01-02?apiKey=secret&unadjusted=false"
+var msftQuotes = "https://api.polygon.io/v2/ticks/stocks/nbbo/MSFT/2018-02-02?apiKey=secret&limit=0"
+var tslaTrades = "https://api.polygon.io/v2/ticks/stocks/trades/TSLA/2018-02-02?apiKey=secret&limit=0"
+
+//seedCache writes entries below a new temp dir through a FileCacher and returns the dir and each entry's path
+func seedCache(t *testing.T, entries []seedEntry) (string, []string) {
+	dir, err := ioutil.TempDir("", "cmd-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, e := range entries {
+		fc := polygonio.FileCacher{Dir: dir, FileCacherIo: polygonio.OsFileCacherIo{}, Encoding: e.encoding}
+		r, _ := http.NewRequest("GET", e.url, nil)
+		status := e.status
+		if status == 0 {
+			status = 200
+		}
+		raw := fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", status, http.StatusText(status), len(e.body), e.body)
+		resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fc.Save(r, resp); err != nil {
+			t.Fatal(err)
+		}
+		d, fn := fc.FilePath(r)
+		paths = append(paths, filepath.Join(d, fn))
+	}
+	return dir, paths
+}
+
+//cachedBody reads the entry of url below dir the way the client does
+func cachedBody(t *testing.T, dir string, url string) string {
+	fc := polygonio.FileCacher{Dir: dir, FileCacherIo: polygonio.OsFileCacherIo{}}
+	r, _ := http.NewRequest("GET", url, nil)
+	resp, err := fc.Get(r)
+	if err != nil || resp == nil {
+		t.Fatalf("Get(%v) = %v, %v", url, resp, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+//dirCacher is the FileCacher the cache commands get for dir
+func dirCacher(dir string) polygonio.FileCacher {
+	return polygonio.FileCacher{Dir: dir, FileCacherIo: polygonio.OsFileCacherIo{}}
+}
+
+//setFlag sets a flag value for the rest of the test
+func setFlag(t *testing.T, name string, value string) func() {
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() { flag.Set(name, old) }
+}
+
+func TestPruneSelect(t *testing.T) {
+
+	now := time.Date(2020, 4, 25, 12, 0, 0, 0, time.UTC)
+	entries := []cacheEntry{
+		{Path: "c", Ticker: "AAPL", Endpoint: "nbbo", Size: 10, Fetched: now.Add(-1 * time.Hour)},
+		{Path: "a", Ticker: "AAPL", Endpoint: "aggregates", Size: 10, Fetched: now.Add(-3 * time.Hour)},
+		{Path: "b", Ticker: "MSFT", Endpoint: "aggregates", Size: 10, Fetched: now.Add(-2 * time.Hour)},
+	}
+	paths := func(in []cacheEntry) []string {
+		out := []string{}
+		for _, e := range in {
+			out = append(out, e.Path)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		olderThan time.Duration
+		ticker    string
+		endpoint  string
+		maxBytes  int64
+		want      []string
+	}{
+		{name: "age", olderThan: 2 * time.Hour, want: []string{"a", "b"}},
+		{name: "ticker", ticker: "AAPL", want: []string{"a", "c"}},
+		{name: "endpoint and age", endpoint: "aggregates", olderThan: 150 * time.Minute, want: []string{"a"}},
+		{name: "budget", maxBytes: 15, want: []string{"a", "b"}},
+		{name: "budget within ticker", ticker: "MSFT", maxBytes: 25, want: []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append([]cacheEntry(nil), entries...)
+			got := paths(pruneSelect(in, now, tt.olderThan, tt.ticker, tt.endpoint, tt.maxBytes))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pruneSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+
+	dir, paths := seedCache(t, []seedEntry{
+		{url: aaplAggregates, body: `{"status":"OK","results":[]}`},
+		{url: msftQuotes, body: `{"results":[]}`, encoding: polygonio.EncodingGzip},
+	})
+	defer os.RemoveAll(dir)
+	defer setFlag(t, "ticker", "AAPL")()
+
+	out := &bytes.Buffer{}
+	if err := cachePrune(dirCacher(dir), out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("AAPL entry kept, %v", err)
+	}
+	//directories of the pruned entry go with it, those holding other entries stay
+	if _, err := os.Stat(filepath.Join(dir, "https", "api.polygon.io", "v2", "aggs")); !os.IsNotExist(err) {
+		t.Errorf("empty directories kept, %v", err)
+	}
+	if _, err := os.Stat(paths[1]); err != nil {
+		t.Errorf("MSFT entry removed, %v", err)
+	}
+	if !strings.Contains(out.String(), "remove "+filepath.Join("https", "api.polygon.io", "v2", "aggs", "ticker", "AAPL")) {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestCacheVerify(t *testing.T) {
+
+	good := []seedEntry{
+		{url: aaplAggregates, body: `{"status":"OK","results":[]}`},
+		{url: msftQuotes, body: `{"results":[]}`, encoding: polygonio.EncodingGzip},
+	}
+	tests := []struct {
+		name string
+		add  []seedEntry
+		//applied to the path of the last entry
+		damage      func(path string) error
+		wantErr     bool
+		wantCorrupt string
+	}{
+		{name: "clean"},
+		{name: "body is not json", add: []seedEntry{{url: tslaTrades, body: `<html>`}}, wantErr: true, wantCorrupt: "trades"},
+		{name: "error status stored", add: []seedEntry{{url: tslaTrades, status: 500, body: `{}`}}, wantErr: true, wantCorrupt: "trades"},
+		{
+			name: "truncated gzip",
+			add:  []seedEntry{{url: tslaTrades, body: `{"results":[]}`, encoding: polygonio.EncodingGzip}},
+			damage: func(path string) error {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				return os.Truncate(path, info.Size()-10)
+			},
+			wantErr:     true,
+			wantCorrupt: "trades",
+		},
+		{
+			name:        "not a cache entry",
+			add:         []seedEntry{{url: tslaTrades, body: `{"results":[]}`}},
+			damage:      func(path string) error { return ioutil.WriteFile(path, []byte("garbage"), 0644) },
+			wantErr:     true,
+			wantCorrupt: "trades",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, paths := seedCache(t, append(append([]seedEntry(nil), good...), tt.add...))
+			defer os.RemoveAll(dir)
+			if tt.damage != nil {
+				if err := tt.damage(paths[len(paths)-1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			out := &bytes.Buffer{}
+			err := cacheVerify(dirCacher(dir), out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cacheVerify() = %v, wantErr %v\n%s", err, tt.wantErr, out)
+			}
+			corrupt := 0
+			if tt.wantCorrupt != "" {
+				corrupt = 1
+			}
+			if !strings.Contains(out.String(), fmt.Sprintf("%d entries, %d corrupt", len(paths), corrupt)) {
+				t.Errorf("output:\n%s", out)
+			}
+			if tt.wantCorrupt != "" && !strings.Contains(out.String(), "corrupt "+filepath.Join("https", "api.polygon.io", "v2", "ticks", "stocks", tt.wantCorrupt)) {
+				t.Errorf("corrupt entry not named:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCacheExportImport(t *testing.T) {
+
+	entries := []seedEntry{
+		{url: aaplAggregates, body: `{"status":"OK","results":[]}`},
+		{url: msftQuotes, body: `{"results":[]}`, encoding: polygonio.EncodingGzip},
+	}
+	src, paths := seedCache(t, entries)
+	defer os.RemoveAll(src)
+	work, err := ioutil.TempDir("", "cmd-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(work)
+	archive := filepath.Join(work, "cache.tar")
+
+	if err := cacheExport(dirCacher(src), ""); err == nil {
+		t.Error("cacheExport() without an archive path, want an error")
+	}
+	if err := cacheExport(dirCacher(src), archive); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		archive string
+		wantErr bool
+		//entries expected in the destination afterwards
+		wantEntries int
+	}{
+		{name: "dry run", dryRun: true, archive: archive, wantEntries: 0},
+		{name: "import", archive: archive, wantEntries: len(entries)},
+		{name: "no archive", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setFlag(t, "dry-run", fmt.Sprint(tt.dryRun))()
+			dst := filepath.Join(work, strings.Replace(tt.name, " ", "-", -1))
+
+			out := &bytes.Buffer{}
+			err := cacheImport(dirCacher(dst), tt.archive, out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cacheImport() = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := walkCache(dirCacher(dst))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.wantEntries {
+				t.Fatalf("imported %v entries, want %v\n%s", len(got), tt.wantEntries, out)
+			}
+			if tt.wantEntries == 0 {
+				return
+			}
+			//stored bytes are copied as they are, compressed entries included
+			for i, e := range entries {
+				rel, _ := filepath.Rel(src, paths[i])
+				want, _ := ioutil.ReadFile(paths[i])
+				if b, err := ioutil.ReadFile(filepath.Join(dst, rel)); err != nil || !bytes.Equal(b, want) {
+					t.Errorf("%v differs after import, %v", rel, err)
+				}
+				if body := cachedBody(t, dst, e.url); body != e.body {
+					t.Errorf("body of %v = %v, want %v", e.url, body, e.body)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheImport_OutsideCache(t *testing.T) {
+
+	work, err := ioutil.TempDir("", "cmd-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(work)
+
+	archive := filepath.Join(work, "evil.tar")
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	tw.WriteHeader(&tar.Header{Name: "../escaped.json", Mode: 0644, Size: 2})
+	tw.Write([]byte("{}"))
+	tw.Close()
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cacheImport(dirCacher(filepath.Join(work, "cache")), archive, ioutil.Discard); err == nil {
+		t.Error("cacheImport() of a path outside the cache, want an error")
+	}
+	if _, err := os.Stat(filepath.Join(work, "escaped.json")); !os.IsNotExist(err) {
+		t.Errorf("entry written outside the cache, %v", err)
+	}
+}
+
+func TestCacheMigrate(t *testing.T) {
+
+	//legacy raw entries next to compressed ones, with a Date header to drop
+	entries := []seedEntry{
+		{url: aaplAggregates, body: `{"status":"OK","results":[]}`},
+		{url: msftQuotes, body: `{"results":[]}`, encoding: polygonio.EncodingGzip},
+		{url: tslaTrades, body: `{"results":[{"p":1}]}`},
+	}
+	tests := []struct {
+		name         string
+		encoding     string
+		dropHeaders  bool
+		dryRun       bool
+		wantEncoding polygonio.CacheEncoding
+		wantMigrated int
+	}{
+		{name: "to gzip", encoding: "gzip", wantEncoding: polygonio.EncodingGzip, wantMigrated: 2},
+		{name: "to raw", encoding: "raw", wantEncoding: polygonio.EncodingRaw, wantMigrated: 1},
+		{name: "drop headers", encoding: "gzip", dropHeaders: true, wantEncoding: polygonio.EncodingGzip, wantMigrated: 3},
+		{name: "dry run", encoding: "gzip", dryRun: true, wantMigrated: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, paths := seedCache(t, entries)
+			defer os.RemoveAll(dir)
+			for _, path := range paths {
+				stored, _ := ioutil.ReadFile(path)
+				//Date is volatile, FileCacher keeps it unless told otherwise
+				stored = bytes.Replace(stored, []byte("Content-Type"), []byte("Date: Sat, 25 Apr 2020 23:27:09 GMT\r\nContent-Type"), 1)
+				if bytes.HasPrefix(stored, []byte("HTTP/")) {
+					ioutil.WriteFile(path, stored, 0644)
+				}
+			}
+			corrupt := filepath.Join(dir, "https", "api.polygon.io", "v1", "last_quote", "stocks", "AAPL", "apiKey=X.json")
+			os.MkdirAll(filepath.Dir(corrupt), 0755)
+			if err := ioutil.WriteFile(corrupt, []byte("garbage"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			before := [][]byte{}
+			for _, path := range paths {
+				b, _ := ioutil.ReadFile(path)
+				before = append(before, b)
+			}
+			defer setFlag(t, "encoding", tt.encoding)()
+			defer setFlag(t, "drop-headers", fmt.Sprint(tt.dropHeaders))()
+			defer setFlag(t, "dry-run", fmt.Sprint(tt.dryRun))()
+
+			out := &bytes.Buffer{}
+			if err := cacheMigrate(dirCacher(dir), out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), fmt.Sprintf("migrated %d of 4 entries", tt.wantMigrated)) || !strings.Contains(out.String(), "skip corrupt") {
+				t.Errorf("first run output:\n%s", out)
+			}
+			if b, _ := ioutil.ReadFile(corrupt); string(b) != "garbage" {
+				t.Errorf("corrupt entry rewritten to %q", b)
+			}
+
+			migrated := [][]byte{}
+			for i, path := range paths {
+				b, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				migrated = append(migrated, b)
+				if tt.dryRun {
+					if !bytes.Equal(b, before[i]) {
+						t.Errorf("dry run changed %v", path)
+					}
+					continue
+				}
+				resp, encoding, err := polygonio.DecodeCacheEntry(bytes.NewReader(b), nil)
+				if err != nil {
+					t.Fatalf("DecodeCacheEntry(%v) = %v", path, err)
+				}
+				body, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if encoding != tt.wantEncoding || string(body) != entries[i].body {
+					t.Errorf("%v decoded as %v %q, want %v %q", path, encoding, body, tt.wantEncoding, entries[i].body)
+				}
+				if hasDate := resp.Header.Get("Date") != ""; hasDate == tt.dropHeaders && i != 1 {
+					t.Errorf("%v Date header kept = %v, drop headers %v", path, hasDate, tt.dropHeaders)
+				}
+				if body := cachedBody(t, dir, entries[i].url); body != entries[i].body {
+					t.Errorf("client reads %q from %v", body, path)
+				}
+			}
+			if tt.dryRun {
+				return
+			}
+
+			//running it again changes nothing
+			out.Reset()
+			if err := cacheMigrate(dirCacher(dir), out); err != nil {
+				t.Fatal(err)
+			}
+			wantAgain := 0
+			if tt.dropHeaders {
+				wantAgain = len(entries)
+			}
+			if !strings.Contains(out.String(), fmt.Sprintf("migrated %d of 4 entries", wantAgain)) {
+				t.Errorf("second run output:\n%s", out)
+			}
+			for i, path := range paths {
+				if b, _ := ioutil.ReadFile(path); !bytes.Equal(b, migrated[i]) {
+					t.Errorf("second run changed %v", path)
+				}
+			}
+		})
+	}
+}
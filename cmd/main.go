@@ -18,15 +18,37 @@ var (
 	timespan   = flag.String("timespan", "hour", "")
 	multiplier = flag.Int64("multiplier", 1, "")
 	search     = flag.String("search", "", "")
+	cacheDir   = flag.String("cachedir", "cache", "relative to the working directory unless absolute")
+	mode       = flag.String("mode", "cache-first", "cache-first, cache-only, network-only or refresh")
 )
 
+func cacheDirectory() string {
+	if filepath.IsAbs(*cacheDir) {
+		return *cacheDir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(wd, *cacheDir)
+}
+
 func PolygonClient() polygonio.PolygonioClient {
 	client := polygonio.NewPolygonioClient(os.Getenv("apiKey"), http.DefaultClient)
-	wd, err := os.Getwd()
+	enc, err := polygonio.ParseCacheEncoding(*encoding)
+	if err != nil {
+		panic(err)
+	}
+	client.Cacher = polygonio.FileCacher{
+		Dir:                 cacheDirectory(),
+		FileCacherIo:        polygonio.OsFileCacherIo{},
+		Encoding:            enc,
+		DropVolatileHeaders: *dropHdrs,
+	}
+	client.Mode, err = polygonio.ParseCacheMode(*mode)
 	if err != nil {
 		panic(err)
 	}
-	client.Cacher = polygonio.FileCacher{Dir: filepath.Join(wd, "cache"), FileCacherIo: polygonio.OsFileCacherIo{}}
 	return client
 }
 
@@ -38,6 +60,8 @@ func main() {
 		cmdLast()
 	case "search":
 		cmdSearch()
+	case "cache":
+		cmdCache()
 	default:
 		flag.Usage()
 	}
@@ -61,13 +85,17 @@ func cmdSearch() {
 	if err != nil {
 		panic(err)
 	}
+	_timespan, err := polygonio.ParseTimespan(*timespan)
+	if err != nil {
+		panic(err)
+	}
 
 	client := PolygonClient()
 
 	resp, err := client.AggregatesSearch(context.Background(), polygonio.AggregatesRequest{
 		Ticker:     *ticker,
 		Multiplier: *multiplier,
-		Timespan:   *timespan,
+		Timespan:   _timespan,
 		Unadjusted: false,
 	}, _search)
 	if err != nil {
@@ -0,0 +1,129 @@
+package polygonio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type CoalesceStats struct {
+	//requests that went through to the cache or network
+	Flights int64
+	//requests that joined a flight already in progress
+	Coalesced int64
+	//requests whose context ended before their flight finished
+	Abandoned int64
+}
+
+//Coalescer runs concurrent identical GET requests once, keyed by RequestKey so the apiKey does not matter.
+//Every caller gets its own copy of the response. A flight is only cancelled once all of its callers gave up,
+//so a cancelled leader does not fail the others. Must be shared by pointer
+type Coalescer struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+	stats   CoalesceStats
+}
+
+type flight struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+	dump    []byte
+	err     error
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{}
+}
+
+func (c *Coalescer) Stats() CoalesceStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+//Do calls do with r unless an identical request is already in flight, in which case it waits for that one
+func (c *Coalescer) Do(r *http.Request, do func(r *http.Request) (*http.Response, error)) (*http.Response, error) {
+	if r.Method != "GET" {
+		return do(r)
+	}
+	return c.do(RequestKey(r), r, do)
+}
+
+//do joins the flight of key, callers whose do differs for the same request need keys of their own
+func (c *Coalescer) do(key string, r *http.Request, do func(r *http.Request) (*http.Response, error)) (*http.Response, error) {
+	c.mu.Lock()
+	if c.flights == nil {
+		c.flights = map[string]*flight{}
+	}
+	f, ok := c.flights[key]
+	if ok {
+		f.waiters++
+		c.stats.Coalesced++
+	} else {
+		ctx, cancel := context.WithCancel(detached{r.Context()})
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.flights[key] = f
+		c.stats.Flights++
+		go c.run(key, f, r.WithContext(ctx), do)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-r.Context().Done():
+		c.mu.Lock()
+		c.stats.Abandoned++
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			//later callers start a new flight instead of joining a cancelled one
+			if c.flights[key] == f {
+				delete(c.flights, key)
+			}
+		}
+		c.mu.Unlock()
+		return nil, r.Context().Err()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(f.dump)), r)
+}
+
+func (c *Coalescer) run(key string, f *flight, r *http.Request, do func(r *http.Request) (*http.Response, error)) {
+	defer f.cancel()
+	resp, err := do(r)
+	if err == nil {
+		f.dump, err = dump(resp)
+	}
+	f.err = err
+
+	c.mu.Lock()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	c.mu.Unlock()
+	close(f.done)
+}
+
+//detached keeps the values of a context but not its deadline or cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
@@ -0,0 +1,224 @@
+package polygonio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+//blockingServer counts requests and holds them until release is closed
+func blockingServer(calls *int32, release chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(`{"results":[{"o":1,"c":2,"h":3,"l":0.5,"v":100,"t":1587736800000}]}`))
+	}))
+}
+
+func coalescingClient(server *httptest.Server) PolygonioClient {
+	u, _ := url.Parse(server.URL)
+	return PolygonioClient{
+		HTTPClient: server.Client(),
+		APIKey:     "secret",
+		BaseHost:   u.Host,
+		BaseScheme: u.Scheme,
+		Coalescer:  NewCoalescer(),
+	}
+}
+
+//waitFor polls until n callers are waiting on the coalescer
+func waitFor(t *testing.T, c *Coalescer, n int64) {
+	for i := 0; i < 200; i++ {
+		stats := c.Stats()
+		if stats.Flights+stats.Coalesced >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("callers did not arrive, stats %+v", c.Stats())
+}
+
+func TestCoalescer_Aggregates(t *testing.T) {
+
+	calls := int32(0)
+	release := make(chan struct{})
+	server := blockingServer(&calls, release)
+	defer server.Close()
+	pc := coalescingClient(server)
+
+	request := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork), To: time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork)}
+	const callers = 5
+	results := make([]*AggregatesResponseContainer, callers)
+	errs := make([]error, callers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = pc.Aggregates(context.Background(), request)
+		}(i)
+	}
+	waitFor(t, pc.Coalescer, callers)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("calls = %v, want 1", calls)
+	}
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if len(results[i].Results) != 1 || !results[i].Results[0].Close.Equal(decimal.NewFromInt(2)) {
+			t.Errorf("caller %v got %+v", i, results[i])
+		}
+	}
+	if stats := pc.Coalescer.Stats(); stats.Flights != 1 || stats.Coalesced != callers-1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	//finished flights are not reused
+	if _, err := pc.Aggregates(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %v, want 2", calls)
+	}
+}
+
+func TestCoalescer_Modes(t *testing.T) {
+
+	calls := int32(0)
+	release := make(chan struct{})
+	server := blockingServer(&calls, release)
+	defer server.Close()
+	pc := coalescingClient(server)
+	pc.Cacher = NewMemoryCacher(0, 0)
+
+	request := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork), To: time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork)}
+	//the cache first call leads, the others arrive while it is in flight
+	modes := []CacheMode{CacheFirst, Refresh, Refresh, NetworkOnly, CacheFirst}
+	errs := make([]error, len(modes))
+	wg := sync.WaitGroup{}
+	for i, mode := range modes {
+		wg.Add(1)
+		go func(i int, mode CacheMode) {
+			defer wg.Done()
+			_, errs[i] = pc.Aggregates(WithCacheMode(context.Background(), mode), request)
+		}(i, mode)
+		waitFor(t, pc.Coalescer, int64(i+1))
+	}
+	close(release)
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil {
+			t.Errorf("%v: %v", modes[i], errs[i])
+		}
+	}
+	//one flight per mode
+	if calls != 3 {
+		t.Errorf("calls = %v, want 3", calls)
+	}
+	if stats := pc.Coalescer.Stats(); stats.Flights != 3 || stats.Coalesced != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCoalescer_Cancel(t *testing.T) {
+
+	tests := []struct {
+		name string
+		//cancel every caller instead of only the leader
+		cancelAll     bool
+		wantFollower  bool
+		wantAbandoned int64
+	}{
+		{name: "leader cancelled", wantFollower: true, wantAbandoned: 1},
+		{name: "all cancelled", cancelAll: true, wantAbandoned: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := int32(0)
+			release := make(chan struct{})
+			server := blockingServer(&calls, release)
+			defer server.Close()
+			c := NewCoalescer()
+			serverCtx := make(chan context.Context, 1)
+
+			do := func(r *http.Request) (*http.Response, error) {
+				serverCtx <- r.Context()
+				return server.Client().Do(r)
+			}
+			newRequest := func(ctx context.Context, apiKey string) *http.Request {
+				r, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/last_quote/stocks/AAPL?apiKey="+apiKey, nil)
+				return r
+			}
+
+			leaderCtx, cancelLeader := context.WithCancel(context.Background())
+			followerCtx, cancelFollower := context.WithCancel(context.Background())
+			defer cancelFollower()
+
+			leaderErr := make(chan error, 1)
+			go func() {
+				_, err := c.Do(newRequest(leaderCtx, "a"), do)
+				leaderErr <- err
+			}()
+			waitFor(t, c, 1)
+			type result struct {
+				resp *http.Response
+				err  error
+			}
+			follower := make(chan result, 1)
+			go func() {
+				resp, err := c.Do(newRequest(followerCtx, "b"), do)
+				follower <- result{resp, err}
+			}()
+			waitFor(t, c, 2)
+
+			cancelLeader()
+			if err := <-leaderErr; err != context.Canceled {
+				t.Errorf("leader err = %v, want context.Canceled", err)
+			}
+			if tt.cancelAll {
+				cancelFollower()
+			} else {
+				close(release)
+			}
+
+			got := <-follower
+			if tt.wantFollower {
+				if got.err != nil {
+					t.Fatalf("follower err = %v", got.err)
+				}
+				if body := readBody(t, got.resp); body == "" {
+					t.Error("follower got an empty body")
+				}
+			} else {
+				if got.err != context.Canceled {
+					t.Errorf("follower err = %v, want context.Canceled", got.err)
+				}
+				select {
+				case <-(<-serverCtx).Done():
+				case <-time.After(time.Second):
+					t.Error("flight was not cancelled once every caller gave up")
+				}
+				close(release)
+			}
+			if stats := c.Stats(); stats.Abandoned != tt.wantAbandoned {
+				t.Errorf("Abandoned = %v, want %v", stats.Abandoned, tt.wantAbandoned)
+			}
+		})
+	}
+}
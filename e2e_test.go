@@ -0,0 +1,151 @@
+package polygonio_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+	"github.com/maerlyn5/polygonio/fakepolygon"
+)
+
+func TestE2E_HistoricQuotesAll(t *testing.T) {
+
+	date := time.Date(2020, 4, 24, 0, 0, 0, 0, polygonio.AmericaNewYork)
+	s := fakepolygon.New("apiKey")
+	s.Quotes["AAPL"] = fakepolygon.SyntheticQuotes(date, 1000, 1)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	pc := fakepolygon.NewClient(ts, "apiKey")
+	for _, reverse := range []bool{false, true} {
+		got, err := pc.HistoricQuotesAll(context.Background(), polygonio.HistoricQuotesRequest{Ticker: "AAPL", Date: date, Limit: 70, Reverse: reverse})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := s.Quotes["AAPL"]
+		if reverse {
+			want = append([]polygonio.HistoricQuotesResponse(nil), want...)
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("reverse %v len = %v, want %v", reverse, len(got), len(want))
+		}
+		for i := range got {
+			if got[i].SequenceNumber != want[i].SequenceNumber {
+				t.Fatalf("reverse %v quote %d = %v, want %v", reverse, i, got[i].SequenceNumber, want[i].SequenceNumber)
+			}
+		}
+	}
+}
+
+func TestE2E_HistoricQuotesAllOversizedLimit(t *testing.T) {
+
+	date := time.Date(2020, 4, 24, 0, 0, 0, 0, polygonio.AmericaNewYork)
+	s := fakepolygon.New("apiKey")
+	s.Quotes["AAPL"] = fakepolygon.SyntheticQuotes(date, polygonio.HistoricQuotesMaxLimit+1000, 1)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	pc := fakepolygon.NewClient(ts, "apiKey")
+	got, err := pc.HistoricQuotesAll(context.Background(), polygonio.HistoricQuotesRequest{Ticker: "AAPL", Date: date, Limit: 2 * polygonio.HistoricQuotesMaxLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(s.Quotes["AAPL"]) {
+		t.Errorf("len = %v, want %v", len(got), len(s.Quotes["AAPL"]))
+	}
+}
+
+func TestE2E_FileCacher(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "polygonio-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	from := time.Date(2020, 4, 24, 9, 30, 0, 0, polygonio.AmericaNewYork)
+	s := fakepolygon.New("apiKey")
+	s.Aggregates["AAPL"] = fakepolygon.SyntheticAggregates(from, from.Add(390*time.Minute), time.Minute, 1)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	pc := fakepolygon.NewClient(ts, "apiKey")
+	pc.Cacher = polygonio.FileCacher{Dir: dir, FileCacherIo: polygonio.OsFileCacherIo{}}
+
+	request := polygonio.AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: from, To: from}
+	first, err := pc.Aggregates(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pc.Aggregates(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(first.Results) != 390 || !reflect.DeepEqual(first, second) {
+		t.Errorf("cached response differs, %d vs %d results", len(first.Results), len(second.Results))
+	}
+	if got := len(s.Requests()); got != 1 {
+		t.Errorf("Requests() = %v, want 1", got)
+	}
+}
+
+func TestE2E_AggregatesSearch(t *testing.T) {
+
+	friday := time.Date(2020, 4, 24, 9, 30, 0, 0, polygonio.AmericaNewYork)
+	monday := time.Date(2020, 4, 27, 9, 30, 0, 0, polygonio.AmericaNewYork)
+	s := fakepolygon.New("apiKey")
+	s.Aggregates["AAPL"] = append(
+		fakepolygon.SyntheticAggregates(friday, friday.Add(390*time.Minute), time.Hour, 1),
+		fakepolygon.SyntheticAggregates(monday, monday.Add(390*time.Minute), time.Hour, 2)...,
+	)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	pc := fakepolygon.NewClient(ts, "apiKey")
+	search := time.Date(2020, 4, 25, 12, 0, 0, 0, polygonio.AmericaNewYork)
+
+	got, err := pc.AggregatesSearch(context.Background(), polygonio.AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "hour"}, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].UnixMiliSecInTime().Before(search) || !got[1].UnixMiliSecInTime().After(search) {
+		t.Errorf("AggregatesSearch() = %v, want the bars either side of the weekend", got)
+	}
+}
+
+func TestE2E_Faults(t *testing.T) {
+
+	s := fakepolygon.New("apiKey")
+	s.LastQuotes["AAPL"] = polygonio.LastQuoteResponse{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	pc := fakepolygon.NewClient(ts, "apiKey")
+	pc.Retry = &polygonio.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	s.Inject(fakepolygon.Fault{Status: 429, RetryAfter: "0", Times: 2})
+	if _, err := pc.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"}); err != nil {
+		t.Errorf("err = %v, want recovery after two 429", err)
+	}
+
+	s.Inject(fakepolygon.Fault{Status: 500})
+	_, err := pc.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"})
+	var ae *polygonio.APIError
+	if !errors.As(err, &ae) || ae.StatusCode != 500 || strings.Contains(ae.URL, "apiKey=apiKey") {
+		t.Errorf("err = %v, want a redacted 500 APIError", err)
+	}
+	if got := len(s.Requests()); got != 6 {
+		t.Errorf("Requests() = %v, want 6", got)
+	}
+}
@@ -0,0 +1,110 @@
+package polygonio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//match with errors.Is(err, ErrRateLimited)
+var (
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrNotEntitled  = errors.New("not entitled")
+)
+
+/*
+{
+  "status": "NOT_AUTHORIZED",
+  "request_id": "a3a6d8e8b9e8f1c1a2b3c4d5e6f7a8b9",
+  "message": "You are not entitled to this data. Please upgrade your plan at https://polygon.io/pricing"
+}
+*/
+
+//APIError is returned for every non 200 response. It unwraps to StatusError
+//so errors.Is(err, StatusError(404)) and errors.As keep working
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"status"`
+	Err        string `json:"error"`
+	Message    string `json:"message"`
+	RequestID  string `json:"request_id"`
+	//the PolygonioClient method that made the request
+	Endpoint string `json:"-"`
+	//request url with the apiKey redacted
+	URL string `json:"-"`
+}
+
+//largest error body that is read, polygon's are a few hundred bytes
+const maxErrorBody = 64 << 10
+
+func newAPIError(endpoint string, resp *http.Response) error {
+	ae := &APIError{StatusCode: resp.StatusCode, Endpoint: endpoint}
+	if resp.Request != nil {
+		ae.URL = RedactURL(resp.Request.URL.String())
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err == nil && len(body) > 0 {
+		//best effort, proxies in between may not answer with json
+		json.Unmarshal(body, ae)
+	}
+	ae.StatusCode = resp.StatusCode
+	return ae
+}
+
+//RedactURL replaces the apiKey query value so urls can be logged
+func RedactURL(raw string) string {
+	i := strings.Index(raw, "apiKey=")
+	if i < 0 {
+		return raw
+	}
+	i += len("apiKey=")
+	j := strings.IndexByte(raw[i:], '&')
+	if j < 0 {
+		return raw[:i] + "REDACTED"
+	}
+	return raw[:i] + "REDACTED" + raw[i+j:]
+}
+
+func (ae *APIError) Error() string {
+	msg := ae.Message
+	if msg == "" {
+		msg = ae.Err
+	}
+	if msg == "" {
+		msg = http.StatusText(ae.StatusCode)
+	}
+	out := fmt.Sprintf("%s: htttpStatus:%d", ae.Endpoint, ae.StatusCode)
+	if ae.Status != "" {
+		out += " " + ae.Status
+	}
+	out += ": " + msg
+	if ae.RequestID != "" {
+		out += " (request_id " + ae.RequestID + ")"
+	}
+	return out
+}
+
+func (ae *APIError) Unwrap() error {
+	return StatusError(ae.StatusCode)
+}
+
+func (ae *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return ae.StatusCode == http.StatusTooManyRequests
+	case ErrUnauthorized:
+		return ae.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return ae.StatusCode == http.StatusNotFound
+	case ErrNotEntitled:
+		return ae.StatusCode == http.StatusForbidden
+	}
+	return false
+}
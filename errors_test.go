@@ -0,0 +1,117 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantIs        []error
+		wantNot       []error
+		wantRequestID string
+		wantMessage   string
+	}{
+		{
+			name:          "rate limited",
+			status:        429,
+			body:          `{"status":"ERROR","request_id":"abc","error":"You've exceeded the maximum requests per minute"}`,
+			wantIs:        []error{ErrRateLimited, StatusError(429)},
+			wantNot:       []error{ErrNotFound, ErrUnauthorized, ErrNotEntitled},
+			wantRequestID: "abc",
+		},
+		{
+			name:          "not entitled",
+			status:        403,
+			body:          `{"status":"NOT_AUTHORIZED","request_id":"def","message":"You are not entitled to this data."}`,
+			wantIs:        []error{ErrNotEntitled, StatusError(403)},
+			wantNot:       []error{ErrUnauthorized, ErrRateLimited},
+			wantRequestID: "def",
+			wantMessage:   "You are not entitled to this data.",
+		},
+		{
+			name:    "unknown key",
+			status:  401,
+			body:    `{"status":"ERROR","error":"Unknown API Key"}`,
+			wantIs:  []error{ErrUnauthorized, StatusError(401)},
+			wantNot: []error{ErrNotEntitled},
+		},
+		{
+			name:    "not json",
+			status:  404,
+			body:    `<html>not found</html>`,
+			wantIs:  []error{ErrNotFound, StatusError(404)},
+			wantNot: []error{StatusError(500)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{HTTPClient: server.Client(), APIKey: "secret", BaseHost: u.Host, BaseScheme: u.Scheme}
+
+			_, err := pc.LastQuote(context.Background(), LastQuoteRequest{Ticker: "AAPL"})
+			for _, target := range tt.wantIs {
+				if !errors.Is(err, target) {
+					t.Errorf("errors.Is(%v, %v) = false", err, target)
+				}
+			}
+			for _, target := range tt.wantNot {
+				if errors.Is(err, target) {
+					t.Errorf("errors.Is(%v, %v) = true", err, target)
+				}
+			}
+
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("expected *APIError got %T", err)
+			}
+			var se StatusError
+			if !errors.As(err, &se) || int(se) != tt.status {
+				t.Errorf("StatusError = %v, want %v", se, tt.status)
+			}
+			if ae.Endpoint != "LastQuote" {
+				t.Errorf("Endpoint = %v", ae.Endpoint)
+			}
+			if ae.RequestID != tt.wantRequestID {
+				t.Errorf("RequestID = %v, want %v", ae.RequestID, tt.wantRequestID)
+			}
+			if ae.Message != tt.wantMessage {
+				t.Errorf("Message = %v, want %v", ae.Message, tt.wantMessage)
+			}
+			if strings.Contains(ae.URL, "secret") || strings.Contains(err.Error(), "secret") {
+				t.Errorf("apiKey leaked in %v", ae.URL)
+			}
+		})
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "https://api.polygon.io/v1/last_quote/stocks/AAPL?apiKey=secret", want: "https://api.polygon.io/v1/last_quote/stocks/AAPL?apiKey=REDACTED"},
+		{in: "https://api.polygon.io/v2/aggs?apiKey=secret&unadjusted=false", want: "https://api.polygon.io/v2/aggs?apiKey=REDACTED&unadjusted=false"},
+		{in: "https://api.polygon.io/v2/aggs?unadjusted=false", want: "https://api.polygon.io/v2/aggs?unadjusted=false"},
+	}
+	for _, tt := range tests {
+		if got := RedactURL(tt.in); got != tt.want {
+			t.Errorf("RedactURL(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
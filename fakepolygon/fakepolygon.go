@@ -0,0 +1,321 @@
+//Package fakepolygon is an in process stand-in for the polygon rest api. Serve it with
+//httptest.NewServer and point a PolygonioClient at it with NewClient
+package fakepolygon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+)
+
+//Fault is injected into matching requests instead of the normal answer
+type Fault struct {
+	//prefix of the request path, "" matches every route
+	Path string
+	//status written instead of the normal answer, 0 keeps the normal answer (useful with Delay)
+	Status     int
+	RetryAfter string
+	//wait before answering, or until the client gives up
+	Delay time.Duration
+	//number of requests the fault applies to, 0 means forever
+	Times int
+}
+
+//Server holds the data it serves, keyed by ticker. Aggregates are served as stored,
+//regardless of the requested multiplier and timespan
+type Server struct {
+	//"" accepts any key
+	APIKey string
+
+	Aggregates map[string][]polygonio.AggregatesResponse
+	Quotes     map[string][]polygonio.HistoricQuotesResponse
+	Trades     map[string][]polygonio.HistoricTradesResponse
+	LastQuotes map[string]polygonio.LastQuoteResponse
+
+	mu       sync.Mutex
+	faults   []*Fault
+	requests []string
+}
+
+func New(apiKey string) *Server {
+	return &Server{
+		APIKey:     apiKey,
+		Aggregates: map[string][]polygonio.AggregatesResponse{},
+		Quotes:     map[string][]polygonio.HistoricQuotesResponse{},
+		Trades:     map[string][]polygonio.HistoricTradesResponse{},
+		LastQuotes: map[string]polygonio.LastQuoteResponse{},
+	}
+}
+
+//NewClient returns a client talking to ts
+func NewClient(ts *httptest.Server, apiKey string) polygonio.PolygonioClient {
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		panic(err)
+	}
+	client := polygonio.NewPolygonioClient(apiKey, ts.Client())
+	client.BaseScheme = u.Scheme
+	client.BaseHost = u.Host
+	return client
+}
+
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+//Requests returns the path of every request served so far, faults included
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) fault(path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, path)
+	for i, f := range s.faults {
+		if !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+		out := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &out
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f := s.fault(r.URL.Path); f != nil {
+		if f.Delay > 0 {
+			t := time.NewTimer(f.Delay)
+			select {
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+		if f.Status != 0 {
+			if f.RetryAfter != "" {
+				w.Header().Set("Retry-After", f.RetryAfter)
+			}
+			writeError(w, f.Status, "ERROR", http.StatusText(f.Status))
+			return
+		}
+	}
+
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "ERROR", "method not allowed")
+		return
+	}
+	if s.APIKey != "" && r.URL.Query().Get("apiKey") != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "ERROR", "Unknown API Key")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	//v2/aggs/ticker/{ticker}/range/{multiplier}/{timespan}/{from}/{to}
+	case len(parts) == 9 && parts[0] == "v2" && parts[1] == "aggs" && parts[2] == "ticker" && parts[4] == "range":
+		s.serveAggregates(w, r, parts[3], parts[7], parts[8])
+	//v2/ticks/stocks/{nbbo,trades}/{ticker}/{date}
+	case len(parts) == 6 && parts[0] == "v2" && parts[1] == "ticks" && parts[2] == "stocks" && parts[3] == "nbbo":
+		s.serveQuotes(w, r, parts[4], parts[5])
+	case len(parts) == 6 && parts[0] == "v2" && parts[1] == "ticks" && parts[2] == "stocks" && parts[3] == "trades":
+		s.serveTrades(w, r, parts[4], parts[5])
+	//v1/last_quote/stocks/{ticker}
+	case len(parts) == 4 && parts[0] == "v1" && parts[1] == "last_quote" && parts[2] == "stocks":
+		s.serveLastQuote(w, parts[3])
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "route not found")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, polygonStatus string, msg string) {
+	writeJSON(w, status, map[string]string{
+		"status":     polygonStatus,
+		"request_id": "fake",
+		"error":      msg,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+//dates in the path are whole days in new york
+func parseDate(in string) (time.Time, bool) {
+	t, err := time.ParseInLocation("2006-01-02", in, polygonio.AmericaNewYork)
+	return t, err == nil
+}
+
+func (s *Server) serveAggregates(w http.ResponseWriter, r *http.Request, ticker string, fromPath string, toPath string) {
+	from, ok1 := parseDate(fromPath)
+	to, ok2 := parseDate(toPath)
+	if !ok1 || !ok2 {
+		writeError(w, http.StatusBadRequest, "ERROR", "could not parse date")
+		return
+	}
+	to = to.AddDate(0, 0, 1)
+
+	s.mu.Lock()
+	bars := s.Aggregates[ticker]
+	s.mu.Unlock()
+
+	//like polygon, 5000 unless a limit is sent and never more than AggregatesMaxResults
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 5000
+	}
+	if limit > polygonio.AggregatesMaxResults {
+		limit = polygonio.AggregatesMaxResults
+	}
+
+	results := []polygonio.AggregatesResponse{}
+	for _, bar := range bars {
+		start := bar.UnixMiliSecInTime()
+		if !start.Before(from) && start.Before(to) && len(results) < limit {
+			results = append(results, bar)
+		}
+	}
+
+	out := map[string]interface{}{
+		"ticker":       ticker,
+		"status":       "OK",
+		"queryCount":   len(results),
+		"resultsCount": len(results),
+		"adjusted":     r.URL.Query().Get("unadjusted") != "true",
+		"request_id":   "fake",
+	}
+	//like polygon, no results key when nothing matched
+	if len(results) > 0 {
+		out["results"] = results
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+//tickPage applies the paging parameters of the ticks endpoints to n ticks ordered by timestamp ts(i)
+func tickPage(r *http.Request, n int, ts func(i int) int64) []int {
+	q := r.URL.Query()
+	timestamp, _ := strconv.ParseInt(q.Get("timestamp"), 10, 64)
+	timestampLimit, _ := strconv.ParseInt(q.Get("timestampLimit"), 10, 64)
+	reverse := q.Get("reverse") == "true"
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 5000
+	}
+	if limit > polygonio.HistoricQuotesMaxLimit {
+		limit = polygonio.HistoricQuotesMaxLimit
+	}
+
+	out := []int{}
+	for k := 0; k < n && len(out) < limit; k++ {
+		i := k
+		if reverse {
+			i = n - 1 - k
+		}
+		t := ts(i)
+		if timestamp != 0 && ((!reverse && t < timestamp) || (reverse && t > timestamp)) {
+			continue
+		}
+		if timestampLimit != 0 && ((!reverse && t > timestampLimit) || (reverse && t < timestampLimit)) {
+			break
+		}
+		out = append(out, i)
+	}
+	return out
+}
+
+func onDate(unixNano int64, date time.Time) bool {
+	t := time.Unix(0, unixNano).In(polygonio.AmericaNewYork)
+	return polygonio.DateFormat(t) == polygonio.DateFormat(date)
+}
+
+func (s *Server) serveQuotes(w http.ResponseWriter, r *http.Request, ticker string, datePath string) {
+	date, ok := parseDate(datePath)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "ERROR", "could not parse date")
+		return
+	}
+
+	s.mu.Lock()
+	day := []polygonio.HistoricQuotesResponse{}
+	for _, q := range s.Quotes[ticker] {
+		if onDate(q.SipUnixNano, date) {
+			day = append(day, q)
+		}
+	}
+	s.mu.Unlock()
+
+	results := []polygonio.HistoricQuotesResponse{}
+	for _, i := range tickPage(r, len(day), func(i int) int64 { return day[i].SipUnixNano }) {
+		results = append(results, day[i])
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ticker":        ticker,
+		"success":       true,
+		"results_count": len(results),
+		"results":       results,
+	})
+}
+
+func (s *Server) serveTrades(w http.ResponseWriter, r *http.Request, ticker string, datePath string) {
+	date, ok := parseDate(datePath)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "ERROR", "could not parse date")
+		return
+	}
+
+	s.mu.Lock()
+	day := []polygonio.HistoricTradesResponse{}
+	for _, t := range s.Trades[ticker] {
+		if onDate(t.SipUnixNano, date) {
+			day = append(day, t)
+		}
+	}
+	s.mu.Unlock()
+
+	results := []polygonio.HistoricTradesResponse{}
+	for _, i := range tickPage(r, len(day), func(i int) int64 { return day[i].SipUnixNano }) {
+		results = append(results, day[i])
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ticker":        ticker,
+		"success":       true,
+		"results_count": len(results),
+		"results":       results,
+	})
+}
+
+func (s *Server) serveLastQuote(w http.ResponseWriter, ticker string) {
+	s.mu.Lock()
+	last, ok := s.LastQuotes[ticker]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "ticker not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"symbol": ticker,
+		"last":   last,
+	})
+}
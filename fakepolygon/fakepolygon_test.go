@@ -0,0 +1,88 @@
+package fakepolygon
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+	"github.com/shopspring/decimal"
+)
+
+func TestServer_LoadFixtures(t *testing.T) {
+
+	s := New("apiKey")
+	if err := s.LoadFixtures("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	pc := NewClient(ts, "apiKey")
+
+	aggs, err := pc.Aggregates(context.Background(), polygonio.AggregatesRequest{
+		Ticker:     "AAPL",
+		Multiplier: 1,
+		Timespan:   "hour",
+		From:       time.Date(2019, 01, 02, 0, 0, 0, 0, polygonio.AmericaNewYork),
+		To:         time.Date(2019, 01, 02, 0, 0, 0, 0, polygonio.AmericaNewYork),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggs.Results) != 3 || !aggs.Results[2].Volume.Equal(decimal.NewFromInt(101580)) {
+		t.Errorf("Results = %v", aggs.Results)
+	}
+
+	last, err := pc.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.Last.BidPrice.Equal(decimal.RequireFromString("159.45")) {
+		t.Errorf("BidPrice = %v", last.Last.BidPrice)
+	}
+
+	if _, err := pc.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "MSFT"}); !errors.Is(err, polygonio.ErrNotFound) {
+		t.Errorf("err = %v, want %v", err, polygonio.ErrNotFound)
+	}
+}
+
+func TestServer_APIKey(t *testing.T) {
+
+	ts := httptest.NewServer(New("apiKey"))
+	defer ts.Close()
+
+	_, err := NewClient(ts, "wrong").LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"})
+	if !errors.Is(err, polygonio.ErrUnauthorized) {
+		t.Errorf("err = %v, want %v", err, polygonio.ErrUnauthorized)
+	}
+}
+
+func TestServer_Inject(t *testing.T) {
+
+	s := New("")
+	s.LastQuotes["AAPL"] = polygonio.LastQuoteResponse{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	pc := NewClient(ts, "apiKey")
+
+	s.Inject(Fault{Path: "/v1/last_quote", Status: 429, Times: 1})
+	if _, err := pc.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"}); !errors.Is(err, polygonio.ErrRateLimited) {
+		t.Errorf("err = %v, want %v", err, polygonio.ErrRateLimited)
+	}
+	if _, err := pc.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"}); err != nil {
+		t.Errorf("err = %v after the fault ran out", err)
+	}
+
+	s.Inject(Fault{Delay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pc.LastQuote(ctx, polygonio.LastQuoteRequest{Ticker: "AAPL"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if got := len(s.Requests()); got != 3 {
+		t.Errorf("Requests() = %v, want 3", got)
+	}
+}
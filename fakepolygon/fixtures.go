@@ -0,0 +1,150 @@
+package fakepolygon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+	"github.com/shopspring/decimal"
+)
+
+//LoadFixtures reads api responses saved as json files named after their ticker
+//
+//  dir/aggs/AAPL.json        an aggregates response
+//  dir/nbbo/AAPL.json        a historic quotes response, any number of days
+//  dir/trades/AAPL.json      a historic trades response, any number of days
+//  dir/last_quote/AAPL.json  a last quote response
+//
+//missing directories are skipped
+func (s *Server) LoadFixtures(dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := loadDir(filepath.Join(dir, "aggs"), func(ticker string, b []byte) error {
+		out := polygonio.AggregatesResponseContainer{}
+		if err := json.Unmarshal(b, &out); err != nil {
+			return err
+		}
+		s.Aggregates[ticker] = out.Results
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := loadDir(filepath.Join(dir, "nbbo"), func(ticker string, b []byte) error {
+		out := polygonio.HistoricQuotesResponseContainer{}
+		if err := json.Unmarshal(b, &out); err != nil {
+			return err
+		}
+		s.Quotes[ticker] = out.Results
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := loadDir(filepath.Join(dir, "trades"), func(ticker string, b []byte) error {
+		out := polygonio.HistoricTradesResponseContainer{}
+		if err := json.Unmarshal(b, &out); err != nil {
+			return err
+		}
+		s.Trades[ticker] = out.Results
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return loadDir(filepath.Join(dir, "last_quote"), func(ticker string, b []byte) error {
+		out := polygonio.LastQuoteResponseContainer{}
+		if err := json.Unmarshal(b, &out); err != nil {
+			return err
+		}
+		s.LastQuotes[ticker] = out.Last
+		return nil
+	})
+}
+
+func loadDir(dir string, load func(ticker string, b []byte) error) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err := load(strings.TrimSuffix(f.Name(), ".json"), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//SyntheticAggregates random walks a bar every step in [from, to), the same seed gives the same bars
+func SyntheticAggregates(from time.Time, to time.Time, step time.Duration, seed int64) []polygonio.AggregatesResponse {
+	r := rand.New(rand.NewSource(seed))
+	price := decimal.NewFromInt(100)
+	cent := decimal.New(1, -2)
+
+	out := []polygonio.AggregatesResponse{}
+	for t := from; t.Before(to); t = t.Add(step) {
+		open := price
+		move := func() decimal.Decimal { return cent.Mul(decimal.NewFromInt(r.Int63n(21) - 10)) }
+		price = price.Add(move())
+		high := decimal.Max(open, price).Add(cent.Mul(decimal.NewFromInt(r.Int63n(5))))
+		low := decimal.Min(open, price).Sub(cent.Mul(decimal.NewFromInt(r.Int63n(5))))
+		out = append(out, polygonio.AggregatesResponse{
+			Volume:      decimal.NewFromInt(100 + r.Int63n(10000)),
+			Open:        open,
+			Close:       price,
+			High:        high,
+			Low:         low,
+			UnixMiliSec: t.UnixNano() / int64(time.Millisecond),
+			N:           1 + r.Int63n(100),
+		})
+	}
+	return out
+}
+
+//SyntheticQuotes spreads n quotes over the regular session of date in new york. Roughly one
+//in ten repeats the previous timestamp, which is what trips up naive pagination
+func SyntheticQuotes(date time.Time, n int, seed int64) []polygonio.HistoricQuotesResponse {
+	r := rand.New(rand.NewSource(seed))
+	open := time.Date(date.Year(), date.Month(), date.Day(), 9, 30, 0, 0, polygonio.AmericaNewYork)
+	step := int64(390*time.Minute) / int64(n+1)
+	bid := decimal.NewFromInt(100)
+	cent := decimal.New(1, -2)
+
+	out := []polygonio.HistoricQuotesResponse{}
+	ts := open.UnixNano()
+	for i := 0; i < n; i++ {
+		if i == 0 || r.Intn(10) != 0 {
+			ts += 1 + r.Int63n(step)
+		}
+		bid = bid.Add(cent.Mul(decimal.NewFromInt(r.Int63n(3) - 1)))
+		out = append(out, polygonio.HistoricQuotesResponse{
+			BIDPrice:            bid,
+			BidSize:             1 + r.Int63n(10),
+			BidExchange:         11,
+			AskPrice:            bid.Add(cent.Mul(decimal.NewFromInt(1 + r.Int63n(3)))),
+			AskSize:             1 + r.Int63n(10),
+			AskExchange:         12,
+			SipUnixNano:         ts,
+			ParticipantUnixNano: ts - 1000,
+			SequenceNumber:      int64(i + 1),
+			Tap:                 3,
+		})
+	}
+	return out
+}
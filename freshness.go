@@ -0,0 +1,80 @@
+package polygonio
+
+import (
+	"net/http"
+	"time"
+)
+
+//ExpiresHeader is stamped on cached responses that may still change, cachers reject the entry once it passes
+const ExpiresHeader = "X-Polygonio-Expires"
+
+//how long data of a session that is still in progress is served from cache unless PolygonioClient.LiveTTL says otherwise
+const DefaultLiveTTL = time.Minute
+
+//Freshness decides whether and for how long DoCache may keep a response
+type Freshness struct {
+	NoStore bool
+	//zero never expires
+	Expires time.Time
+}
+
+var (
+	Immutable = Freshness{}
+	NoStore   = Freshness{NoStore: true}
+)
+
+//SessionComplete is true once no more data can arrive for date, after midnight in new york
+func SessionComplete(date time.Time, now time.Time) bool {
+	end := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, AmericaNewYork).AddDate(0, 0, 1)
+	return !now.Before(end)
+}
+
+//LiveFreshness is the freshness of data that is still changing, ttl <= 0 does not store it
+func LiveFreshness(now time.Time, ttl time.Duration) Freshness {
+	if ttl <= 0 {
+		return NoStore
+	}
+	return Freshness{Expires: now.Add(ttl)}
+}
+
+//ranges ending before the last completed session never change
+func AggregatesFreshness(request AggregatesRequest, now time.Time, ttl time.Duration) Freshness {
+	if SessionComplete(request.To, now) {
+		return Immutable
+	}
+	return LiveFreshness(now, ttl)
+}
+
+//freshness of the ticks endpoints, quotes and trades of a single day
+func TicksFreshness(date time.Time, now time.Time, ttl time.Duration) Freshness {
+	if SessionComplete(date, now) {
+		return Immutable
+	}
+	return LiveFreshness(now, ttl)
+}
+
+//stamped is the copy of resp a cacher stores, resp keeps its headers and the copy shares its body
+func (f Freshness) stamped(resp *http.Response) *http.Response {
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	if stored.Header == nil {
+		stored.Header = http.Header{}
+	}
+	if f.Expires.IsZero() {
+		stored.Header.Del(ExpiresHeader)
+	} else {
+		stored.Header.Set(ExpiresHeader, f.Expires.UTC().Format(http.TimeFormat))
+	}
+	return &stored
+}
+
+//Expired is true for a cached response stamped with an expiry at or before now
+func Expired(resp *http.Response, now time.Time) bool {
+	header := resp.Header.Get(ExpiresHeader)
+	if header == "" {
+		return false
+	}
+	expires, err := http.ParseTime(header)
+	//unreadable means we cannot trust it
+	return err != nil || !now.Before(expires)
+}
@@ -0,0 +1,152 @@
+package polygonio
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregatesFreshness(t *testing.T) {
+
+	//friday 2020-04-24 2:00 PM in new york
+	now := time.Date(2020, 4, 24, 14, 0, 0, 0, AmericaNewYork)
+
+	tests := []struct {
+		name string
+		to   time.Time
+		ttl  time.Duration
+		want Freshness
+	}{
+		{name: "yesterday", to: time.Date(2020, 4, 23, 0, 0, 0, 0, AmericaNewYork), ttl: time.Minute, want: Immutable},
+		{name: "today", to: time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork), ttl: time.Minute, want: Freshness{Expires: now.Add(time.Minute)}},
+		{name: "future", to: time.Date(2020, 4, 27, 0, 0, 0, 0, AmericaNewYork), ttl: time.Minute, want: Freshness{Expires: now.Add(time.Minute)}},
+		{name: "today not cached", to: time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork), ttl: -1, want: NoStore},
+		//still the 24th in new york
+		{name: "utc evening", to: time.Date(2020, 4, 24, 23, 0, 0, 0, time.UTC), ttl: time.Minute, want: Freshness{Expires: now.Add(time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AggregatesFreshness(AggregatesRequest{To: tt.to}, now, tt.ttl)
+			if got.NoStore != tt.want.NoStore || !got.Expires.Equal(tt.want.Expires) {
+				t.Errorf("AggregatesFreshness() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCacher_GetExpired(t *testing.T) {
+
+	now := time.Date(2020, 4, 24, 14, 0, 0, 0, time.UTC)
+	stamped := func(expires time.Time) string {
+		return strings.Replace(googleResponse, "Connection: close\n", "Connection: close\n"+ExpiresHeader+": "+expires.Format(http.TimeFormat)+"\n", 1)
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		wantMiss bool
+	}{
+		{name: "immutable", stored: googleResponse},
+		{name: "fresh", stored: stamped(now.Add(time.Second))},
+		{name: "expired", stored: stamped(now), wantMiss: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := FileCacher{Dir: "Test", Clock: &fakeClock{now: now}, FileCacherIo: &TestIoer{
+				read: func(filepath string) (io.ReadCloser, error) {
+					return ioutil.NopCloser(strings.NewReader(tt.stored)), nil
+				},
+			}}
+
+			r, _ := http.NewRequest("GET", "http://www.google.com", nil)
+			resp, err := fc.Get(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (resp == nil) != tt.wantMiss {
+				t.Errorf("FileCacher.Get() = %v, wantMiss %v", resp, tt.wantMiss)
+			}
+		})
+	}
+}
+
+func TestPolygonioClient_LiveAggregatesExpire(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"results":[]}`))
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 14, 0, 0, 0, AmericaNewYork)}
+	u, _ := url.Parse(server.URL)
+	pc := PolygonioClient{
+		HTTPClient: server.Client(),
+		BaseHost:   u.Host,
+		BaseScheme: u.Scheme,
+		Cacher:     &MemoryCacher{Clock: clock},
+		Clock:      clock,
+	}
+
+	today := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: clock.now, To: clock.now}
+	yesterday := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "minute", From: clock.now.AddDate(0, 0, -1), To: clock.now.AddDate(0, 0, -1)}
+
+	for i := 0; i < 2; i++ {
+		for _, request := range []AggregatesRequest{today, yesterday} {
+			if _, err := pc.Aggregates(context.Background(), request); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if calls != 2 {
+		t.Errorf("calls = %v, want 2 within the ttl", calls)
+	}
+
+	clock.now = clock.now.Add(DefaultLiveTTL)
+	for _, request := range []AggregatesRequest{today, yesterday} {
+		if _, err := pc.Aggregates(context.Background(), request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("calls = %v, want 3 once only today expired", calls)
+	}
+}
+
+func TestDoCacheFreshness_StampsStoredCopy(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[]}`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2020, 4, 24, 14, 0, 0, 0, AmericaNewYork)
+	mc := &MemoryCacher{Clock: &fakeClock{now: now}}
+	r, _ := http.NewRequest("GET", server.URL+"/v2/aggs/ticker/AAPL", nil)
+
+	resp, err := DoCacheFreshness(server.Client(), r, LiveFreshness(now, time.Minute), mc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(ExpiresHeader); got != "" {
+		t.Errorf("caller's response stamped with %v: %v", ExpiresHeader, got)
+	}
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != `{"results":[]}` {
+		t.Errorf("body = %q", body)
+	}
+
+	cached, err := mc.Get(r)
+	if err != nil || cached == nil {
+		t.Fatalf("Get() = %v, %v", cached, err)
+	}
+	if got := cached.Header.Get(ExpiresHeader); got != now.Add(time.Minute).UTC().Format(http.TimeFormat) {
+		t.Errorf("stored %v = %q", ExpiresHeader, got)
+	}
+}
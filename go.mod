@@ -0,0 +1,10 @@
+module github.com/maerlyn5/polygonio
+
+go 1.21
+
+require (
+	github.com/shopspring/decimal v1.2.0
+	go.etcd.io/bbolt v1.3.10
+)
+
+require golang.org/x/sys v0.7.0 // indirect
@@ -22,6 +22,12 @@ type HistoricQuotesRequest struct {
 	Limit          int
 }
 
+//Session sets Date to the trading day of the session t belongs to
+func (hqr HistoricQuotesRequest) Session(t time.Time) HistoricQuotesRequest {
+	hqr.Date = FromDate(t)
+	return hqr
+}
+
 /*
 {
   "results": [
@@ -44,16 +50,55 @@ type HistoricQuotesRequest struct {
 
 */
 
+//single letter keys of the v2 nbbo ticks, lower case is the bid side, upper case the ask side.
+//encoding/json prefers an exact key match, so "p" and "P" never land in each other's field
+//
+//  p bid price      P ask price
+//  s bid size       S ask size
+//  x bid exchange   X ask exchange
+//  t sip timestamp  y participant timestamp  f trf timestamp
+//  q sequence number  c conditions  i indicators  z tape
 type HistoricQuotesResponse struct {
 	BIDPrice            decimal.Decimal `json:"p"`
+	BidSize             int64           `json:"s"`
+	BidExchange         int64           `json:"x"`
 	AskPrice            decimal.Decimal `json:"P"`
+	AskSize             int64           `json:"S"`
+	AskExchange         int64           `json:"X"`
 	SipUnixNano         int64           `json:"t"`
 	ParticipantUnixNano int64           `json:"y"`
 	TRFUnixNano         int64           `json:"f"`
-	AskSize             int64           `json:"S"`
+	SequenceNumber      int64           `json:"q"`
+	Conditions          []int64         `json:"c"`
+	Indicators          []int64         `json:"i"`
 	Tap                 int64           `json:"z"`
 }
 
+func (hq HistoricQuotesResponse) Spread() decimal.Decimal {
+	return hq.AskPrice.Sub(hq.BIDPrice)
+}
+
+//same as LastQuoteResponse.Market()
+func (hq HistoricQuotesResponse) Mid() decimal.Decimal {
+	return hq.BIDPrice.Add(hq.AskPrice).Div(decimal.NewFromInt(2))
+}
+
+func (hq HistoricQuotesResponse) SipTime() time.Time {
+	return time.Unix(0, hq.SipUnixNano)
+}
+
+func (hq HistoricQuotesResponse) ParticipantTime() time.Time {
+	return time.Unix(0, hq.ParticipantUnixNano)
+}
+
+//zero time when the quote was not reported to a trf
+func (hq HistoricQuotesResponse) TRFTime() time.Time {
+	if hq.TRFUnixNano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, hq.TRFUnixNano)
+}
+
 type HistoricQuotesResponseContainer struct {
 	Results []HistoricQuotesResponse `json:"results"`
 }
@@ -75,7 +120,7 @@ func (pc PolygonioClient) HistoricQuotesRequest(ctx context.Context, request His
 }
 
 func (pc PolygonioClient) HistoricQuotes(ctx context.Context, request HistoricQuotesRequest) (*HistoricQuotesResponseContainer, error) {
-	resp, err := DoCache(pc.HTTPClient, pc.HistoricQuotesRequest(ctx, request), true, pc.Cacher)
+	resp, err := pc.doCache(pc.HistoricQuotesRequest(ctx, request), TicksFreshness(request.Date, pc.now(), pc.liveTTL()), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -88,5 +133,5 @@ func (pc PolygonioClient) HistoricQuotes(ctx context.Context, request HistoricQu
 		}
 		return out, json.Unmarshal(bytes, out)
 	}
-	return nil, StatusError(resp.StatusCode)
+	return nil, newAPIError("HistoricQuotes", resp)
 }
@@ -0,0 +1,124 @@
+package polygonio
+
+import (
+	"context"
+)
+
+//largest page the ticks endpoints will return
+const HistoricQuotesMaxLimit = 50000
+
+//HistoricQuotesIterator walks every quote of request.Date one page at a time.
+//Each page is a regular HistoricQuotes call, so pages are cached individually
+//and an interrupted day resumes from the cache.
+type HistoricQuotesIterator struct {
+	pc      PolygonioClient
+	ctx     context.Context
+	request HistoricQuotesRequest
+
+	page []HistoricQuotesResponse
+	i    int
+	//number of quotes at the end of the previous page sharing its last timestamp,
+	//polygon returns them again at the start of the next page
+	boundary int
+	//sip timestamp of the last quote of the previous page
+	lastSip int64
+	done    bool
+	err     error
+}
+
+func (pc PolygonioClient) HistoricQuotesIterator(ctx context.Context, request HistoricQuotesRequest) *HistoricQuotesIterator {
+	//a larger limit still gets pages of HistoricQuotesMaxLimit, which would look like the last page
+	if request.Limit <= 0 || request.Limit > HistoricQuotesMaxLimit {
+		request.Limit = HistoricQuotesMaxLimit
+	}
+	return &HistoricQuotesIterator{pc: pc, ctx: ctx, request: request, i: -1}
+}
+
+//Next advances to the next quote, fetching a new page when needed
+func (it *HistoricQuotesIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.i++
+	for it.i >= len(it.page) {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	return true
+}
+
+func (it *HistoricQuotesIterator) fetch() error {
+	if err := it.ctx.Err(); err != nil {
+		return err
+	}
+
+	resp, err := it.pc.HistoricQuotes(it.ctx, it.request)
+	if err != nil {
+		return err
+	}
+	results := resp.Results
+
+	if len(results) < it.request.Limit {
+		it.done = true
+	}
+	if len(results) == 0 {
+		it.page, it.i = nil, 0
+		return nil
+	}
+
+	//only leading repeats of the previous page's last timestamp are dropped, never more than it ended with
+	skip := 0
+	for skip < it.boundary && skip < len(results) && results[skip].SipUnixNano == it.lastSip {
+		skip++
+	}
+
+	last := results[len(results)-1].SipUnixNano
+	it.boundary = 0
+	for i := len(results) - 1; i >= 0 && results[i].SipUnixNano == last; i-- {
+		it.boundary++
+	}
+
+	if it.boundary == len(results) && !it.done {
+		//a full page sharing a single timestamp, step past it rather than asking for it forever
+		it.boundary = 0
+		if it.request.Reverse {
+			last--
+		} else {
+			last++
+		}
+	}
+
+	if int(last) == it.request.Timestamp && skip == len(results) {
+		it.done = true
+	}
+
+	it.request.Timestamp = int(last)
+	it.lastSip = results[len(results)-1].SipUnixNano
+	it.page, it.i = results[skip:], 0
+	return nil
+}
+
+//Quote returns the quote Next advanced to
+func (it *HistoricQuotesIterator) Quote() HistoricQuotesResponse {
+	return it.page[it.i]
+}
+
+func (it *HistoricQuotesIterator) Err() error {
+	return it.err
+}
+
+//HistoricQuotesAll collects every page of request.Date into a single slice
+func (pc PolygonioClient) HistoricQuotesAll(ctx context.Context, request HistoricQuotesRequest) ([]HistoricQuotesResponse, error) {
+	out := []HistoricQuotesResponse{}
+	it := pc.HistoricQuotesIterator(ctx, request)
+	for it.Next() {
+		out = append(out, it.Quote())
+	}
+	return out, it.Err()
+}
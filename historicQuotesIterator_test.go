@@ -0,0 +1,148 @@
+package polygonio
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//serves quotes with the given sip timestamps honouring timestamp, limit and reverse
+func quotesServer(timestamps []int64, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		q := r.URL.Query()
+		timestamp, _ := strconv.ParseInt(q.Get("timestamp"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		reverse := q.Get("reverse") == "true"
+
+		out := HistoricQuotesResponseContainer{Results: []HistoricQuotesResponse{}}
+		for i := range timestamps {
+			ts := timestamps[i]
+			if reverse {
+				ts = timestamps[len(timestamps)-1-i]
+				if timestamp != 0 && ts > timestamp {
+					continue
+				}
+			} else if ts < timestamp {
+				continue
+			}
+			if len(out.Results) == limit {
+				break
+			}
+			out.Results = append(out.Results, HistoricQuotesResponse{SipUnixNano: ts})
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func sipTimestamps(quotes []HistoricQuotesResponse) []int64 {
+	out := []int64{}
+	for _, q := range quotes {
+		out = append(out, q.SipUnixNano)
+	}
+	return out
+}
+
+func TestPolygonioClient_HistoricQuotesAll(t *testing.T) {
+
+	timestamps := []int64{1, 2, 3, 3, 4, 5, 6, 7}
+
+	tests := []struct {
+		name    string
+		request HistoricQuotesRequest
+		want    []int64
+	}{
+		{
+			name:    "forward",
+			request: HistoricQuotesRequest{Ticker: "AAPL", Date: time.Date(2018, 02, 02, 0, 0, 0, 0, time.UTC), Limit: 3},
+			want:    []int64{1, 2, 3, 3, 4, 5, 6, 7},
+		},
+		{
+			name:    "reverse",
+			request: HistoricQuotesRequest{Ticker: "AAPL", Date: time.Date(2018, 02, 02, 0, 0, 0, 0, time.UTC), Limit: 3, Reverse: true},
+			want:    []int64{7, 6, 5, 4, 3, 3, 2, 1},
+		},
+		{
+			name:    "single page",
+			request: HistoricQuotesRequest{Ticker: "AAPL", Date: time.Date(2018, 02, 02, 0, 0, 0, 0, time.UTC)},
+			want:    []int64{1, 2, 3, 3, 4, 5, 6, 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := quotesServer(timestamps, &calls)
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{HTTPClient: server.Client(), APIKey: "apiKey", BaseHost: u.Host, BaseScheme: u.Scheme}
+
+			got, err := pc.HistoricQuotesAll(context.Background(), tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sipTimestamps(got), tt.want) {
+				t.Errorf("PolygonioClient.HistoricQuotesAll() = %v, want %v", sipTimestamps(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonioClient_HistoricQuotesAllPageAfterBoundary(t *testing.T) {
+
+	//pages start strictly after the timestamp asked for, nothing of the previous page is repeated
+	timestamps := []int64{1, 2, 3, 4, 5, 6, 7}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamp, _ := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		out := HistoricQuotesResponseContainer{Results: []HistoricQuotesResponse{}}
+		for _, ts := range timestamps {
+			if ts > timestamp && len(out.Results) < limit {
+				out.Results = append(out.Results, HistoricQuotesResponse{SipUnixNano: ts})
+			}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	pc := PolygonioClient{HTTPClient: server.Client(), APIKey: "apiKey", BaseHost: u.Host, BaseScheme: u.Scheme}
+
+	got, err := pc.HistoricQuotesAll(context.Background(), HistoricQuotesRequest{Ticker: "AAPL", Date: time.Date(2018, 02, 02, 0, 0, 0, 0, time.UTC), Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sipTimestamps(got), timestamps) {
+		t.Errorf("PolygonioClient.HistoricQuotesAll() = %v, want %v", sipTimestamps(got), timestamps)
+	}
+}
+
+func TestHistoricQuotesIterator_Cancelled(t *testing.T) {
+
+	calls := 0
+	server := quotesServer([]int64{1, 2, 3}, &calls)
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	pc := PolygonioClient{HTTPClient: server.Client(), APIKey: "apiKey", BaseHost: u.Host, BaseScheme: u.Scheme}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	it := pc.HistoricQuotesIterator(ctx, HistoricQuotesRequest{Ticker: "AAPL", Limit: 1})
+	if it.Next() {
+		t.Fatal("expected no quotes")
+	}
+	if it.Err() != context.Canceled {
+		t.Errorf("Err() = %v, want %v", it.Err(), context.Canceled)
+	}
+	if calls != 0 {
+		t.Errorf("calls = %v, want 0", calls)
+	}
+}
@@ -2,11 +2,14 @@ package polygonio
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 func TestPolygonioClient_HistoricQuotesRequest(t *testing.T) {
@@ -58,3 +61,88 @@ func TestPolygonioClient_HistoricQuotesRequest(t *testing.T) {
 		})
 	}
 }
+
+var nbboSample = `{
+  "results": [
+    {
+      "t": 1517562000065700400,
+      "y": 1517562000065321200,
+      "f": 1517562000065800000,
+      "q": 2060,
+      "c": [
+        1
+      ],
+      "i": [
+        604
+      ],
+      "z": 3,
+      "p": 102.7,
+      "s": 60,
+      "x": 11,
+      "P": 102.9,
+      "S": 2,
+      "X": 12
+    }
+  ]
+}`
+
+func TestHistoricQuotesResponse_Unmarshal(t *testing.T) {
+
+	out := HistoricQuotesResponseContainer{}
+	if err := json.Unmarshal([]byte(nbboSample), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := HistoricQuotesResponse{
+		BIDPrice:            decimal.RequireFromString("102.7"),
+		BidSize:             60,
+		BidExchange:         11,
+		AskPrice:            decimal.RequireFromString("102.9"),
+		AskSize:             2,
+		AskExchange:         12,
+		SipUnixNano:         1517562000065700400,
+		ParticipantUnixNano: 1517562000065321200,
+		TRFUnixNano:         1517562000065800000,
+		SequenceNumber:      2060,
+		Conditions:          []int64{1},
+		Indicators:          []int64{604},
+		Tap:                 3,
+	}
+
+	if len(out.Results) != 1 {
+		t.Fatalf("len(Results) = %v, want 1", len(out.Results))
+	}
+	got := out.Results[0]
+	if !got.BIDPrice.Equal(want.BIDPrice) || !got.AskPrice.Equal(want.AskPrice) {
+		t.Errorf("bid/ask = %v/%v, want %v/%v", got.BIDPrice, got.AskPrice, want.BIDPrice, want.AskPrice)
+	}
+	got.BIDPrice, got.AskPrice = want.BIDPrice, want.AskPrice
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HistoricQuotesResponse = %+v, want %+v", got, want)
+	}
+}
+
+func TestHistoricQuotesResponse_SpreadMid(t *testing.T) {
+	tests := []struct {
+		name       string
+		bid        string
+		ask        string
+		wantSpread string
+		wantMid    string
+	}{
+		{bid: "102.7", ask: "102.9", wantSpread: "0.2", wantMid: "102.8"},
+		{name: "locked", bid: "10", ask: "10", wantSpread: "0", wantMid: "10"},
+		{name: "crossed", bid: "10.05", ask: "10", wantSpread: "-0.05", wantMid: "10.025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hq := HistoricQuotesResponse{BIDPrice: decimal.RequireFromString(tt.bid), AskPrice: decimal.RequireFromString(tt.ask)}
+			if got := hq.Spread(); !got.Equal(decimal.RequireFromString(tt.wantSpread)) {
+				t.Errorf("HistoricQuotesResponse.Spread() = %v, want %v", got, tt.wantSpread)
+			}
+			if got := hq.Mid(); !got.Equal(decimal.RequireFromString(tt.wantMid)) {
+				t.Errorf("HistoricQuotesResponse.Mid() = %v, want %v", got, tt.wantMid)
+			}
+		})
+	}
+}
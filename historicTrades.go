@@ -0,0 +1,101 @@
+package polygonio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+//https://polygon.io/docs/#get_v2_ticks_stocks_trades__ticker___date__anchor
+type HistoricTradesRequest struct {
+	Ticker         string
+	Date           time.Time
+	Timestamp      int
+	TimestampLimit int
+	Reverse        bool
+	Limit          int
+}
+
+//Session sets Date to the trading day of the session t belongs to
+func (htr HistoricTradesRequest) Session(t time.Time) HistoricTradesRequest {
+	htr.Date = FromDate(t)
+	return htr
+}
+
+/*
+{
+  "results": [
+    {
+      "t": 1517562000016036600,
+      "y": 1517562000015577000,
+      "f": 1517562000016036600,
+      "q": 1063,
+      "i": "1",
+      "x": 11,
+      "s": 100,
+      "c": [
+        12,
+        41
+      ],
+      "p": 171.55,
+      "z": 3
+    },
+...
+
+*/
+
+type HistoricTradesResponse struct {
+	Price               decimal.Decimal `json:"p"`
+	Size                int64           `json:"s"`
+	Exchange            int64           `json:"x"`
+	Conditions          []int64         `json:"c"`
+	TradeID             string          `json:"i"`
+	SequenceNumber      int64           `json:"q"`
+	SipUnixNano         int64           `json:"t"`
+	ParticipantUnixNano int64           `json:"y"`
+	TRFUnixNano         int64           `json:"f"`
+	Tape                int64           `json:"z"`
+}
+
+type HistoricTradesResponseContainer struct {
+	Results []HistoricTradesResponse `json:"results"`
+}
+
+func (pc PolygonioClient) HistoricTradesRequest(ctx context.Context, request HistoricTradesRequest) *http.Request {
+	base := pc.URL()
+	base.Path = fmt.Sprintf("/v2/ticks/stocks/trades/%s/%s", request.Ticker, DateFormat(request.Date))
+	q := base.Query()
+	q.Add("timestamp", strconv.Itoa(request.Timestamp))
+	q.Add("timestampLimit", strconv.Itoa(request.TimestampLimit))
+	q.Add("reverse", strconv.FormatBool(request.Reverse))
+	q.Add("limit", strconv.Itoa(request.Limit))
+	base.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", base.String(), nil)
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
+
+func (pc PolygonioClient) HistoricTrades(ctx context.Context, request HistoricTradesRequest) (*HistoricTradesResponseContainer, error) {
+	resp, err := pc.doCache(pc.HistoricTradesRequest(ctx, request), TicksFreshness(request.Date, pc.now(), pc.liveTTL()), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 200 {
+		out := &HistoricTradesResponseContainer{}
+		bytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return out, json.Unmarshal(bytes, out)
+	}
+	return nil, newAPIError("HistoricTrades", resp)
+}
@@ -0,0 +1,92 @@
+package polygonio
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolygonioClient_HistoricTradesRequest(t *testing.T) {
+
+	anyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		panic(err)
+	}
+
+	type fields struct {
+		HTTPClient *http.Client
+		APIKey     string
+		BaseHost   string
+		BaseScheme string
+	}
+	type args struct {
+		ctx     context.Context
+		request HistoricTradesRequest
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   string
+	}{
+		{
+			fields: fields{APIKey: "apiKey", BaseHost: "base", BaseScheme: "http"},
+			args:   args{ctx: context.Background(), request: HistoricTradesRequest{Ticker: "AAPL", Date: time.Date(2018, 02, 02, 0, 0, 0, 0, anyc)}},
+			want:   "http://base/v2/ticks/stocks/trades/AAPL/2018-02-02?apiKey=apiKey&limit=0&reverse=false&timestamp=0&timestampLimit=0",
+		},
+		{
+			name:   "paging",
+			fields: fields{APIKey: "apiKey", BaseHost: "base", BaseScheme: "http"},
+			args: args{ctx: context.Background(), request: HistoricTradesRequest{
+				Ticker:         "AAPL",
+				Date:           time.Date(2018, 02, 02, 0, 0, 0, 0, anyc),
+				Timestamp:      1517562000016036600,
+				TimestampLimit: 1517562000016036700,
+				Reverse:        true,
+				Limit:          50000,
+			}},
+			want: "http://base/v2/ticks/stocks/trades/AAPL/2018-02-02?apiKey=apiKey&limit=50000&reverse=true&timestamp=1517562000016036600&timestampLimit=1517562000016036700",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := PolygonioClient{
+				HTTPClient: tt.fields.HTTPClient,
+				APIKey:     tt.fields.APIKey,
+				BaseHost:   tt.fields.BaseHost,
+				BaseScheme: tt.fields.BaseScheme,
+			}
+
+			wantUrl, err := url.Parse(tt.want)
+			if err != nil {
+				panic(err)
+			}
+
+			if got := pc.HistoricTradesRequest(tt.args.ctx, tt.args.request); !reflect.DeepEqual(got.URL, wantUrl) {
+				t.Errorf("PolygonioClient.HistoricTrades() = %v, want %v", got, wantUrl)
+			}
+		})
+	}
+}
+
+func TestFileCacher_FilePath_HistoricTrades(t *testing.T) {
+
+	pc := PolygonioClient{APIKey: "secret", BaseHost: "base", BaseScheme: "http"}
+	fc := FileCacher{Dir: "Test"}
+
+	dir, fn := fc.FilePath(pc.HistoricTradesRequest(context.Background(), HistoricTradesRequest{
+		Ticker: "AAPL",
+		Date:   time.Date(2018, 02, 02, 0, 0, 0, 0, time.UTC),
+	}))
+
+	if want := filepath.Join("Test", "http", "base", "v2", "ticks", "stocks", "trades", "AAPL", "2018-02-02"); dir != want {
+		t.Errorf("dir = %v, want %v", dir, want)
+	}
+	if want := "apiKey=X&limit=0&reverse=false&timestamp=0&timestampLimit=0.json"; fn != want {
+		t.Errorf("fn = %v, want %v", fn, want)
+	}
+}
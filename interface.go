@@ -9,9 +9,19 @@ import (
 )
 
 type Polygonio interface {
+	Aggregates(ctx context.Context, request AggregatesRequest) (*AggregatesResponseContainer, error)
+	AggregatesRange(ctx context.Context, request AggregatesRequest) (*AggregatesResponseContainer, error)
+	AggregatesSearch(ctx context.Context, request AggregatesRequest, search time.Time) ([]AggregatesResponse, error)
+	AggregatesSearchWith(ctx context.Context, request AggregatesRequest, search time.Time, options SearchOptions) ([]AggregatesResponse, error)
 	HistoricQuotes(ctx context.Context, request HistoricQuotesRequest) (*HistoricQuotesResponseContainer, error)
+	HistoricQuotesAll(ctx context.Context, request HistoricQuotesRequest) ([]HistoricQuotesResponse, error)
+	HistoricTrades(ctx context.Context, request HistoricTradesRequest) (*HistoricTradesResponseContainer, error)
+	LastQuote(ctx context.Context, request LastQuoteRequest) (*LastQuoteResponseContainer, error)
 }
 
+var _ Polygonio = PolygonioClient{}
+
+//Cacher is the original cache interface, see CacherV2 for context, deletion and enumeration
 type Cacher interface {
 	//should only return error if response is unuseable
 	Save(request *http.Request, response *http.Response) error
@@ -28,6 +38,63 @@ type PolygonioClient struct {
 	BaseHost   string
 	BaseScheme string
 	Cacher     Cacher
+	//nil makes a single attempt per request
+	Retry *RetryPolicy
+	//nil does not limit, every retry attempt takes its own token
+	RateLimiter RateLimiter
+	//how long data of a session still in progress is cached, 0 means DefaultLiveTTL and negative does not cache it
+	LiveTTL time.Duration
+	//nil means SystemClock
+	Clock Clock
+	//nil makes every call on its own, shared by all copies of the client
+	Coalescer *Coalescer
+	//overridden per call by WithCacheMode
+	Mode CacheMode
+	//chunks AggregatesRange fetches at once, 0 fetches them one after another
+	AggregatesConcurrency int
+	//days AggregatesSearch expects bars on, nil means calendar.USEquities
+	Calendar TradingCalendar
+}
+
+func (pc PolygonioClient) now() time.Time {
+	if pc.Clock == nil {
+		return time.Now()
+	}
+	return pc.Clock.Now()
+}
+
+func (pc PolygonioClient) liveTTL() time.Duration {
+	if pc.LiveTTL == 0 {
+		return DefaultLiveTTL
+	}
+	return pc.LiveTTL
+}
+
+func (pc PolygonioClient) doer() Doer {
+	var doer Doer = pc.HTTPClient
+	if pc.RateLimiter != nil {
+		doer = RateLimitDoer(pc.RateLimiter, doer)
+	}
+	if pc.Retry != nil {
+		doer = pc.Retry.Doer(doer)
+	}
+	return doer
+}
+
+//check is passed to doCacheV2, responses it rejects are returned but not cached
+func (pc PolygonioClient) doCache(r *http.Request, freshness Freshness, check func(body []byte) error) (*http.Response, error) {
+	mode := pc.Mode
+	if m, ok := CacheModeFrom(r.Context()); ok {
+		mode = m
+	}
+	//cache only calls never reach the network, joining a flight that does would break that
+	if pc.Coalescer == nil || mode == CacheOnly || r.Method != "GET" {
+		return doCacheV2(pc.doer(), r, freshness, CacherV2From(pc.Cacher), mode, check)
+	}
+	//flights are per mode, a refresh or network only call must not be answered by a cache first flight
+	return pc.Coalescer.do(mode.String()+" "+RequestKey(r), r, func(r *http.Request) (*http.Response, error) {
+		return doCacheV2(pc.doer(), r, freshness, CacherV2From(pc.Cacher), mode, check)
+	})
 }
 
 type StatusError int
@@ -64,7 +64,7 @@ func (pc PolygonioClient) LastQuoteRequest(ctx context.Context, request LastQuot
 }
 
 func (pc PolygonioClient) LastQuote(ctx context.Context, request LastQuoteRequest) (*LastQuoteResponseContainer, error) {
-	resp, err := DoCache(pc.HTTPClient, pc.LastQuoteRequest(ctx, request), false, pc.Cacher)
+	resp, err := pc.doCache(pc.LastQuoteRequest(ctx, request), NoStore, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -77,5 +77,5 @@ func (pc PolygonioClient) LastQuote(ctx context.Context, request LastQuoteReques
 		}
 		return out, json.Unmarshal(bytes, out)
 	}
-	return nil, StatusError(resp.StatusCode)
+	return nil, newAPIError("LastQuote", resp)
 }
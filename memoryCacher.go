@@ -0,0 +1,271 @@
+package polygonio
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	//misses served by Next and copied into memory
+	Promotions int64
+	Entries    int
+	Bytes      int64
+}
+
+//MemoryCacher keeps serialized responses in memory with least recently used eviction.
+//With Next set it is the front tier of Next: misses fall through and are promoted on a hit,
+//saves are written through. Must be shared by pointer
+type MemoryCacher struct {
+	//0 means unbounded
+	MaxEntries int
+	//0 means unbounded, larger responses are never kept in memory
+	MaxBytes int64
+	Next     Cacher
+	//decides when stamped entries expire, nil means SystemClock
+	Clock Clock
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	stats CacheStats
+}
+
+type memoryEntry struct {
+	key  string
+	dump []byte
+}
+
+func NewMemoryCacher(maxEntries int, maxBytes int64) *MemoryCacher {
+	return &MemoryCacher{MaxEntries: maxEntries, MaxBytes: maxBytes}
+}
+
+//NewTieredCacher fronts next with a memory cache
+func NewTieredCacher(maxEntries int, maxBytes int64, next Cacher) *MemoryCacher {
+	mc := NewMemoryCacher(maxEntries, maxBytes)
+	mc.Next = next
+	return mc
+}
+
+func (mc *MemoryCacher) Stats() CacheStats {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.stats
+}
+
+//dump serializes response and leaves its body readable for the caller
+func dump(response *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	buf := &bytes.Buffer{}
+	if err := response.Write(buf); err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return buf.Bytes(), nil
+}
+
+func (mc *MemoryCacher) Save(request *http.Request, response *http.Response) error {
+	stored := StampFetched(response, mc.now())
+	d, err := dump(stored)
+	response.Body = stored.Body
+	if err != nil {
+		return err
+	}
+	mc.put(RequestKey(request), d)
+
+	if mc.Next != nil {
+		return mc.Next.Save(request, response)
+	}
+	return nil
+}
+
+func (mc *MemoryCacher) Get(request *http.Request) (*http.Response, error) {
+	key := RequestKey(request)
+
+	mc.mu.Lock()
+	if el, ok := mc.items[key]; ok {
+		mc.ll.MoveToFront(el)
+		mc.stats.Hits++
+		d := el.Value.(*memoryEntry).dump
+		mc.mu.Unlock()
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(d)), request)
+		if err != nil || !Expired(resp, mc.now()) {
+			return resp, err
+		}
+		resp.Body.Close()
+		mc.mu.Lock()
+		mc.stats.Hits--
+		mc.remove(el)
+	}
+	mc.stats.Misses++
+	mc.mu.Unlock()
+
+	if mc.Next == nil {
+		return nil, nil
+	}
+
+	resp, err := mc.Next.Get(request)
+	if resp == nil || err != nil {
+		return resp, err
+	}
+
+	d, err := dump(resp)
+	if err != nil {
+		return nil, err
+	}
+	if mc.put(key, d) {
+		mc.mu.Lock()
+		mc.stats.Promotions++
+		mc.mu.Unlock()
+	}
+	return resp, nil
+}
+
+func (mc *MemoryCacher) put(key string, d []byte) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	//the old response is stale whether or not the new one fits.
+	//replacing pushes a new element so an expired one found by Get is never mistaken for it
+	if el, ok := mc.items[key]; ok {
+		mc.remove(el)
+	}
+	if mc.MaxBytes > 0 && int64(len(d)) > mc.MaxBytes {
+		return false
+	}
+	if mc.items == nil {
+		mc.items = map[string]*list.Element{}
+		mc.ll = list.New()
+	}
+	mc.items[key] = mc.ll.PushFront(&memoryEntry{key: key, dump: d})
+	mc.stats.Bytes += int64(len(d))
+	mc.stats.Entries++
+
+	for (mc.MaxEntries > 0 && mc.stats.Entries > mc.MaxEntries) || (mc.MaxBytes > 0 && mc.stats.Bytes > mc.MaxBytes) {
+		mc.remove(mc.ll.Back())
+		mc.stats.Evictions++
+	}
+	return true
+}
+
+func (mc *MemoryCacher) remove(el *list.Element) {
+	entry := el.Value.(*memoryEntry)
+	//an expired entry may have been replaced or evicted while the lock was released
+	if mc.items[entry.key] != el {
+		return
+	}
+	mc.ll.Remove(el)
+	delete(mc.items, entry.key)
+	mc.stats.Bytes -= int64(len(entry.dump))
+	mc.stats.Entries--
+}
+
+func (mc *MemoryCacher) GetContext(ctx context.Context, request *http.Request) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return mc.Get(request)
+}
+
+func (mc *MemoryCacher) SaveContext(ctx context.Context, request *http.Request, response *http.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return mc.Save(request, response)
+}
+
+//Delete removes key from memory and from Next
+func (mc *MemoryCacher) Delete(ctx context.Context, key string) error {
+	mc.mu.Lock()
+	if el, ok := mc.items[key]; ok {
+		mc.remove(el)
+	}
+	mc.mu.Unlock()
+
+	if mc.Next != nil {
+		return CacherV2From(mc.Next).Delete(ctx, key)
+	}
+	return nil
+}
+
+//Stat answers from memory when it holds key, otherwise from Next. It does not count as a hit or miss
+func (mc *MemoryCacher) Stat(ctx context.Context, key string) (CacheEntry, error) {
+	mc.mu.Lock()
+	var d []byte
+	if el, ok := mc.items[key]; ok {
+		d = el.Value.(*memoryEntry).dump
+	}
+	mc.mu.Unlock()
+
+	if d == nil {
+		if mc.Next != nil {
+			return CacherV2From(mc.Next).Stat(ctx, key)
+		}
+		return CacheEntry{}, &NotCachedError{Key: key}
+	}
+	return memoryCacheEntry(key, d)
+}
+
+func memoryCacheEntry(key string, d []byte) (CacheEntry, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(d)), nil)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	resp.Body.Close()
+	entry := NewCacheEntry(key, resp)
+	entry.Size = int64(len(d))
+	return entry, nil
+}
+
+//Walk visits the entries of Next when it is set, saves are written through so it holds everything in memory.
+//Otherwise it visits the entries in memory in key order
+func (mc *MemoryCacher) Walk(ctx context.Context, fn func(entry CacheEntry) error) error {
+	if mc.Next != nil {
+		return CacherV2From(mc.Next).Walk(ctx, fn)
+	}
+
+	//fn may Delete, it runs without the lock on a snapshot
+	mc.mu.Lock()
+	entries := []*memoryEntry{}
+	for _, el := range mc.items {
+		entries = append(entries, el.Value.(*memoryEntry))
+	}
+	mc.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry, err := memoryCacheEntry(e.key, e.dump)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mc *MemoryCacher) now() time.Time {
+	if mc.Clock == nil {
+		return time.Now()
+	}
+	return mc.Clock.Now()
+}
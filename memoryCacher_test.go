@@ -0,0 +1,230 @@
+package polygonio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func cachedResponse(r *http.Request, body string) *http.Response {
+	raw := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), r)
+	if err != nil {
+		panic(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestMemoryCacher_LRU(t *testing.T) {
+
+	mc := NewMemoryCacher(2, 0)
+	reqs := []*http.Request{}
+	for _, ticker := range []string{"AAPL", "MSFT", "TSLA"} {
+		r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/"+ticker+"?apiKey=secret", nil)
+		reqs = append(reqs, r)
+	}
+
+	for i, r := range reqs[:2] {
+		resp := cachedResponse(r, fmt.Sprint(i))
+		if err := mc.Save(r, resp); err != nil {
+			t.Fatal(err)
+		}
+		//save leaves the body for the caller
+		if got := readBody(t, resp); got != fmt.Sprint(i) {
+			t.Errorf("body after Save = %v", got)
+		}
+	}
+
+	//touch AAPL so MSFT is the least recently used
+	if resp, _ := mc.Get(reqs[0]); resp == nil || readBody(t, resp) != "0" {
+		t.Fatal("expected AAPL")
+	}
+	mc.Save(reqs[2], cachedResponse(reqs[2], "2"))
+
+	if resp, _ := mc.Get(reqs[1]); resp != nil {
+		t.Error("expected MSFT to be evicted")
+	}
+	for i, r := range []*http.Request{reqs[0], reqs[2]} {
+		resp, err := mc.Get(r)
+		if err != nil || resp == nil {
+			t.Fatalf("Get(%d) = %v, %v", i, resp, err)
+		}
+		readBody(t, resp)
+	}
+
+	stats := mc.Stats()
+	if stats.Hits != 3 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestMemoryCacher_MaxBytes(t *testing.T) {
+
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL", nil)
+	//the size of the stored copy, stamped with when it was saved
+	size := func(body string) int64 {
+		resp := cachedResponse(r, body)
+		resp.Header.Set(FetchedHeader, time.Now().UTC().Format(http.TimeFormat))
+		d, err := dump(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int64(len(d))
+	}
+
+	mc := NewMemoryCacher(0, size("12345"))
+	mc.Save(r, cachedResponse(r, strings.Repeat("x", 100)))
+	if stats := mc.Stats(); stats.Entries != 0 {
+		t.Errorf("oversized response kept, Stats() = %+v", stats)
+	}
+
+	//an oversized replacement drops the stale entry instead of leaving it to be served
+	mc.Save(r, cachedResponse(r, "123"))
+	mc.Save(r, cachedResponse(r, strings.Repeat("y", 100)))
+	if resp, _ := mc.Get(r); resp != nil {
+		t.Errorf("stale entry served after an oversized replacement: %v", readBody(t, resp))
+	}
+
+	other, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/MSFT", nil)
+	mc.Save(r, cachedResponse(r, "123"))
+	mc.Save(other, cachedResponse(other, "123"))
+	if stats := mc.Stats(); stats.Entries != 1 || stats.Evictions != 1 || stats.Bytes > mc.MaxBytes {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestMemoryCacher_Tiered(t *testing.T) {
+
+	backing := NewMemoryCacher(0, 0)
+	tiered := NewTieredCacher(1, 0, backing)
+
+	r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/AAPL", nil)
+	other, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/MSFT", nil)
+
+	tiered.Save(r, cachedResponse(r, "aapl"))
+	tiered.Save(other, cachedResponse(other, "msft"))
+	if backing.Stats().Entries != 2 {
+		t.Fatalf("write through missing, backing Stats() = %+v", backing.Stats())
+	}
+
+	//AAPL was evicted from the front tier, the backing tier serves and promotes it
+	resp, err := tiered.Get(r)
+	if err != nil || resp == nil || readBody(t, resp) != "aapl" {
+		t.Fatalf("Get() = %v, %v", resp, err)
+	}
+	resp, _ = tiered.Get(r)
+	readBody(t, resp)
+
+	stats := tiered.Stats()
+	if stats.Promotions != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestMemoryCacher_Concurrent(t *testing.T) {
+
+	mc := NewMemoryCacher(8, 0)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				r, _ := http.NewRequest("GET", fmt.Sprintf("http://base/v1/last_quote/stocks/T%d", (g*i)%16), nil)
+				if resp, _ := mc.Get(r); resp != nil {
+					ioutil.ReadAll(resp.Body)
+					continue
+				}
+				mc.Save(r, cachedResponse(r, "x"))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if stats := mc.Stats(); stats.Entries > 8 || stats.Hits+stats.Misses != 800 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestMemoryCacher_CacherV2(t *testing.T) {
+
+	ctx := context.Background()
+	now := time.Date(2020, 4, 24, 14, 0, 0, 0, time.UTC)
+	mc := NewMemoryCacher(0, 0)
+	mc.Clock = &fakeClock{now: now}
+
+	keys := []string{}
+	for _, ticker := range []string{"MSFT", "AAPL"} {
+		r, _ := http.NewRequest("GET", "http://base/v1/last_quote/stocks/"+ticker+"?apiKey=secret", nil)
+		resp := cachedResponse(r, ticker)
+		if err := mc.SaveContext(ctx, r, resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get(FetchedHeader) != "" {
+			t.Error("Save() stamped the caller's response")
+		}
+		if got := readBody(t, resp); got != ticker {
+			t.Errorf("body after Save() = %v", got)
+		}
+		keys = append(keys, RequestKey(r))
+	}
+
+	entry, err := mc.Stat(ctx, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Ticker != "MSFT" || !entry.Fetched.Equal(now) || entry.Size == 0 {
+		t.Errorf("Stat() = %+v", entry)
+	}
+
+	//key order, deleting while walking
+	visited := []string{}
+	stored := mc.Stats().Bytes
+	size := int64(0)
+	if err := mc.Walk(ctx, func(entry CacheEntry) error {
+		visited = append(visited, entry.Ticker)
+		size += entry.Size
+		return mc.Delete(ctx, entry.Key)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(visited, " ") != "AAPL MSFT" || size != stored || mc.Stats().Entries != 0 {
+		t.Errorf("Walk() visited %v of %v bytes, want %v, %+v left", visited, size, stored, mc.Stats())
+	}
+	if _, err := mc.Stat(ctx, keys[0]); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Stat() after Delete() err = %v, want ErrNotCached", err)
+	}
+
+	//tiered, Stat falls through and Delete reaches the backing cacher
+	backing := NewMemoryCacher(0, 0)
+	tiered := NewTieredCacher(1, 0, backing)
+	for _, key := range keys {
+		r, _ := http.NewRequest("GET", key, nil)
+		tiered.Save(r, cachedResponse(r, "x"))
+	}
+	if _, err := tiered.Stat(ctx, keys[0]); err != nil {
+		t.Errorf("Stat() of an entry only in Next = %v", err)
+	}
+	if err := tiered.Delete(ctx, keys[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backing.Stat(ctx, keys[1]); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Delete() left the entry in Next, err = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package polygonio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+//CacheMode decides whether a request reads the cache, the network or both
+type CacheMode int
+
+const (
+	//serve from the cache and fall back to the network, the zero value
+	CacheFirst CacheMode = iota
+	//never make a network request, a miss is a *NotCachedError
+	CacheOnly
+	//always make the network request and touch no cache
+	NetworkOnly
+	//always make the network request and overwrite the cache entry
+	Refresh
+)
+
+func (cm CacheMode) String() string {
+	switch cm {
+	case CacheFirst:
+		return "cache-first"
+	case CacheOnly:
+		return "cache-only"
+	case NetworkOnly:
+		return "network-only"
+	case Refresh:
+		return "refresh"
+	}
+	return fmt.Sprintf("CacheMode(%d)", int(cm))
+}
+
+func ParseCacheMode(in string) (CacheMode, error) {
+	switch in {
+	case "cache-first", "":
+		return CacheFirst, nil
+	case "cache-only":
+		return CacheOnly, nil
+	case "network-only":
+		return NetworkOnly, nil
+	case "refresh":
+		return Refresh, nil
+	}
+	return CacheFirst, fmt.Errorf("unknown cache mode %q", in)
+}
+
+//ErrNotCached matches every *NotCachedError with errors.Is
+var ErrNotCached = errors.New("not cached")
+
+//NotCachedError is returned in CacheOnly mode instead of making a network request
+type NotCachedError struct {
+	//RequestKey of the missing request, without the apiKey
+	Key string
+}
+
+func (nce *NotCachedError) Error() string {
+	return "not cached: " + nce.Key
+}
+
+func (nce *NotCachedError) Is(target error) bool {
+	return target == ErrNotCached
+}
+
+type cacheModeKey struct{}
+
+//WithCacheMode overrides the client's Mode for requests made with ctx
+func WithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+func CacheModeFrom(ctx context.Context) (CacheMode, bool) {
+	mode, ok := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode, ok
+}
+
+//DoCacheMode is DoCacheFreshness with an explicit mode, DoCacheFreshness takes the mode from the request context
+func DoCacheMode(doer Doer, r *http.Request, freshness Freshness, cacher Cacher, mode CacheMode) (*http.Response, error) {
+	return doCacheV2(doer, r, freshness, CacherV2From(cacher), mode, nil)
+}
+
+//check, when not nil, sees the body of a 200 response before it is saved and keeps it out of the cache by
+//returning an error. The response is returned either way, the caller reports the error from its own parse
+func doCacheV2(doer Doer, r *http.Request, freshness Freshness, cacher CacherV2, mode CacheMode, check func(body []byte) error) (*http.Response, error) {
+	ctx := r.Context()
+	cacheable := r.Method == "GET" && !freshness.NoStore && cacher != nil
+
+	switch mode {
+	case CacheOnly:
+		if cacheable {
+			resp, err := cacher.GetContext(ctx, r)
+			if resp != nil && err == nil {
+				return resp, nil
+			}
+		}
+		return nil, &NotCachedError{Key: RequestKey(r)}
+	case NetworkOnly:
+		return doer.Do(r)
+	case CacheFirst:
+		if cacheable {
+			resp, err := cacher.GetContext(ctx, r)
+			if resp != nil && err == nil {
+				return resp, err
+			}
+		}
+	}
+
+	resp, err := doer.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable && resp.StatusCode == 200 {
+		if check != nil {
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if check(body) != nil {
+				return resp, nil
+			}
+		}
+		stored := freshness.stamped(resp)
+		err := cacher.SaveContext(ctx, r, stored)
+		//cachers hand back a readable body on what they were given
+		resp.Body = stored.Body
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,115 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDoCacheMode(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		mode       CacheMode
+		cached     bool
+		wantCalls  int
+		wantBody   string
+		wantErr    error
+		wantCached bool
+	}{
+		{name: "cache first hit", mode: CacheFirst, cached: true, wantBody: "cached", wantCached: true},
+		{name: "cache first miss", mode: CacheFirst, wantCalls: 1, wantBody: "network", wantCached: true},
+		{name: "cache only hit", mode: CacheOnly, cached: true, wantBody: "cached", wantCached: true},
+		{name: "cache only miss", mode: CacheOnly, wantErr: ErrNotCached},
+		{name: "network only", mode: NetworkOnly, cached: true, wantCalls: 1, wantBody: "network", wantCached: true},
+		{name: "network only does not save", mode: NetworkOnly, wantCalls: 1, wantBody: "network"},
+		{name: "refresh", mode: Refresh, cached: true, wantCalls: 1, wantBody: "network", wantCached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Write([]byte("network"))
+			}))
+			defer server.Close()
+
+			r, _ := http.NewRequest("GET", server.URL+"/v1/last_quote/stocks/AAPL?apiKey=secret", nil)
+			mc := NewMemoryCacher(0, 0)
+			if tt.cached {
+				mc.Save(r, cachedResponse(r, "cached"))
+			}
+
+			resp, err := DoCacheFreshness(server.Client(), r.WithContext(WithCacheMode(context.Background(), tt.mode)), Immutable, mc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if err != nil {
+				nce := &NotCachedError{}
+				if !errors.As(err, &nce) || nce.Key != RequestKey(r) {
+					t.Errorf("err = %#v, want the request key", err)
+				}
+				return
+			}
+			if got := readBody(t, resp); got != tt.wantBody {
+				t.Errorf("body = %v, want %v", got, tt.wantBody)
+			}
+
+			cached, _ := mc.Get(r)
+			if (cached != nil) != tt.wantCached {
+				t.Errorf("cached = %v, want %v", cached != nil, tt.wantCached)
+			}
+			if cached != nil && tt.mode == Refresh && readBody(t, cached) != "network" {
+				t.Error("refresh did not overwrite the cache entry")
+			}
+		})
+	}
+}
+
+func TestPolygonioClient_Mode(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"status":"success","last":{"askprice":2,"bidprice":1}}`))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	pc := PolygonioClient{
+		HTTPClient: server.Client(),
+		BaseHost:   u.Host,
+		BaseScheme: u.Scheme,
+		Cacher:     NewMemoryCacher(0, 0),
+		Mode:       CacheOnly,
+	}
+
+	//LastQuote is never cached so a cache only client cannot serve it
+	if _, err := pc.LastQuote(context.Background(), LastQuoteRequest{Ticker: "AAPL"}); !errors.Is(err, ErrNotCached) {
+		t.Errorf("err = %v, want ErrNotCached", err)
+	}
+	ctx := WithCacheMode(context.Background(), CacheFirst)
+	if _, err := pc.LastQuote(ctx, LastQuoteRequest{Ticker: "AAPL"}); err != nil {
+		t.Errorf("err = %v with the mode overridden per call", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %v, want 1", calls)
+	}
+}
+
+func TestParseCacheMode(t *testing.T) {
+	for _, mode := range []CacheMode{CacheFirst, CacheOnly, NetworkOnly, Refresh} {
+		if got, err := ParseCacheMode(mode.String()); err != nil || got != mode {
+			t.Errorf("ParseCacheMode(%v) = %v, %v", mode, got, err)
+		}
+	}
+	if _, err := ParseCacheMode("offline"); err == nil {
+		t.Error("expected an error")
+	}
+}
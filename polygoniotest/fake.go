@@ -0,0 +1,279 @@
+//Package polygoniotest provides a programmable polygonio.Polygonio for unit tests
+package polygoniotest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+)
+
+type Call struct {
+	Method string
+	//the request struct passed to the method
+	Request interface{}
+	//only set for AggregatesSearch and AggregatesSearchWith
+	Search  time.Time
+	Options polygonio.SearchOptions
+}
+
+//Fake answers from canned results keyed by ticker unless the matching Func is set.
+//The zero value is ready to use and answers every call with empty results
+type Fake struct {
+	AggregatesResults map[string][]polygonio.AggregatesResponse
+	QuotesResults     map[string][]polygonio.HistoricQuotesResponse
+	TradesResults     map[string][]polygonio.HistoricTradesResponse
+	LastQuotes        map[string]polygonio.LastQuoteResponse
+
+	AggregatesFunc           func(ctx context.Context, request polygonio.AggregatesRequest) (*polygonio.AggregatesResponseContainer, error)
+	AggregatesRangeFunc      func(ctx context.Context, request polygonio.AggregatesRequest) (*polygonio.AggregatesResponseContainer, error)
+	AggregatesSearchFunc     func(ctx context.Context, request polygonio.AggregatesRequest, search time.Time) ([]polygonio.AggregatesResponse, error)
+	AggregatesSearchWithFunc func(ctx context.Context, request polygonio.AggregatesRequest, search time.Time, options polygonio.SearchOptions) ([]polygonio.AggregatesResponse, error)
+	HistoricQuotesFunc       func(ctx context.Context, request polygonio.HistoricQuotesRequest) (*polygonio.HistoricQuotesResponseContainer, error)
+	HistoricQuotesAllFunc    func(ctx context.Context, request polygonio.HistoricQuotesRequest) ([]polygonio.HistoricQuotesResponse, error)
+	HistoricTradesFunc       func(ctx context.Context, request polygonio.HistoricTradesRequest) (*polygonio.HistoricTradesResponseContainer, error)
+	LastQuoteFunc            func(ctx context.Context, request polygonio.LastQuoteRequest) (*polygonio.LastQuoteResponseContainer, error)
+
+	//returned by the method named in the key, "*" applies to every method
+	Errors map[string]error
+	//every call waits this long first, or until ctx is done
+	Latency time.Duration
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ polygonio.Polygonio = (*Fake)(nil)
+
+//Calls returns every call made so far in order
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+//CallsTo returns the calls made to method
+func (f *Fake) CallsTo(method string) []Call {
+	out := []Call{}
+	for _, c := range f.Calls() {
+		if c.Method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+func (f *Fake) begin(ctx context.Context, call Call) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err, ok := f.Errors[call.Method]; ok && err != nil {
+		return err
+	}
+	if err, ok := f.Errors["*"]; ok && err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *Fake) Aggregates(ctx context.Context, request polygonio.AggregatesRequest) (*polygonio.AggregatesResponseContainer, error) {
+	if err := f.begin(ctx, Call{Method: "Aggregates", Request: request}); err != nil {
+		return nil, err
+	}
+	if f.AggregatesFunc != nil {
+		return f.AggregatesFunc(ctx, request)
+	}
+	if err := checkTimespan(request); err != nil {
+		return nil, err
+	}
+	out := f.aggregates(request)
+	if len(out.Results) == 0 {
+		//like polygon, a window without bars has no results
+		return nil, &polygonio.AggregatesError{Ticker: request.Ticker, Status: "OK", NoResults: true}
+	}
+	return out, nil
+}
+
+//AggregatesRange answers in one piece, the fake has no result cap. Like the client a window without bars
+//is an empty container rather than ErrNoResults
+func (f *Fake) AggregatesRange(ctx context.Context, request polygonio.AggregatesRequest) (*polygonio.AggregatesResponseContainer, error) {
+	if err := f.begin(ctx, Call{Method: "AggregatesRange", Request: request}); err != nil {
+		return nil, err
+	}
+	if f.AggregatesRangeFunc != nil {
+		return f.AggregatesRangeFunc(ctx, request)
+	}
+	if err := checkTimespan(request); err != nil {
+		return nil, err
+	}
+	return f.aggregates(request), nil
+}
+
+//checkTimespan fails requests the client would refuse instead of panicking in ApplyRequest
+func checkTimespan(request polygonio.AggregatesRequest) error {
+	if !request.Timespan.Valid() {
+		return fmt.Errorf("%w %q", polygonio.ErrUnknownTimespan, string(request.Timespan))
+	}
+	return nil
+}
+
+func (f *Fake) aggregates(request polygonio.AggregatesRequest) *polygonio.AggregatesResponseContainer {
+	//same window the api uses, whole days in new york
+	from := time.Date(request.From.Year(), request.From.Month(), request.From.Day(), 0, 0, 0, 0, polygonio.AmericaNewYork)
+	to := time.Date(request.To.Year(), request.To.Month(), request.To.Day(), 0, 0, 0, 0, polygonio.AmericaNewYork).AddDate(0, 0, 1)
+
+	out := &polygonio.AggregatesResponseContainer{Results: []polygonio.AggregatesResponse{}}
+	for _, ar := range f.AggregatesResults[request.Ticker] {
+		start := ar.UnixMiliSecInTime()
+		if (request.From.IsZero() || !start.Before(from)) && (request.To.IsZero() || start.Before(to)) {
+			out.Results = append(out.Results, ar)
+		}
+	}
+	out.ApplyRequest(request)
+	return out
+}
+
+func (f *Fake) AggregatesSearch(ctx context.Context, request polygonio.AggregatesRequest, search time.Time) ([]polygonio.AggregatesResponse, error) {
+	if err := f.begin(ctx, Call{Method: "AggregatesSearch", Request: request, Search: search}); err != nil {
+		return nil, err
+	}
+	if f.AggregatesSearchFunc != nil {
+		return f.AggregatesSearchFunc(ctx, request, search)
+	}
+	if err := checkTimespan(request); err != nil {
+		return nil, err
+	}
+
+	out := polygonio.AggregatesResponseContainer{Results: append([]polygonio.AggregatesResponse(nil), f.AggregatesResults[request.Ticker]...)}
+	sort.Slice(out.Results, func(i, j int) bool { return out.Results[i].UnixMiliSec < out.Results[j].UnixMiliSec })
+	out.ApplyRequest(request)
+	closest, found := out.ClosestAggregate(search)
+	if !found {
+		return nil, polygonio.SearchReturnedNoResults
+	}
+	return closest, nil
+}
+
+//AggregatesSearchWith searches all canned bars of the ticker, there is no lookback
+func (f *Fake) AggregatesSearchWith(ctx context.Context, request polygonio.AggregatesRequest, search time.Time, options polygonio.SearchOptions) ([]polygonio.AggregatesResponse, error) {
+	if err := f.begin(ctx, Call{Method: "AggregatesSearchWith", Request: request, Search: search, Options: options}); err != nil {
+		return nil, err
+	}
+	if f.AggregatesSearchWithFunc != nil {
+		return f.AggregatesSearchWithFunc(ctx, request, search, options)
+	}
+	if err := checkTimespan(request); err != nil {
+		return nil, err
+	}
+
+	out := polygonio.AggregatesResponseContainer{Results: append([]polygonio.AggregatesResponse(nil), f.AggregatesResults[request.Ticker]...)}
+	sort.Slice(out.Results, func(i, j int) bool { return out.Results[i].UnixMiliSec < out.Results[j].UnixMiliSec })
+	out.ApplyRequest(request)
+	found, ok := polygonio.SearchAggregates(out.Results, search, options.Mode)
+	if !ok {
+		return nil, &polygonio.SearchError{Ticker: request.Ticker, Search: search, Mode: options.Mode}
+	}
+	return found, nil
+}
+
+func (f *Fake) HistoricQuotes(ctx context.Context, request polygonio.HistoricQuotesRequest) (*polygonio.HistoricQuotesResponseContainer, error) {
+	if err := f.begin(ctx, Call{Method: "HistoricQuotes", Request: request}); err != nil {
+		return nil, err
+	}
+	if f.HistoricQuotesFunc != nil {
+		return f.HistoricQuotesFunc(ctx, request)
+	}
+	return &polygonio.HistoricQuotesResponseContainer{Results: f.quotes(request)}, nil
+}
+
+func (f *Fake) HistoricQuotesAll(ctx context.Context, request polygonio.HistoricQuotesRequest) ([]polygonio.HistoricQuotesResponse, error) {
+	if err := f.begin(ctx, Call{Method: "HistoricQuotesAll", Request: request}); err != nil {
+		return nil, err
+	}
+	if f.HistoricQuotesAllFunc != nil {
+		return f.HistoricQuotesAllFunc(ctx, request)
+	}
+	request.Limit = 0
+	return f.quotes(request), nil
+}
+
+//canned quotes honouring timestamp, reverse and limit like the api does
+func (f *Fake) quotes(request polygonio.HistoricQuotesRequest) []polygonio.HistoricQuotesResponse {
+	canned := f.QuotesResults[request.Ticker]
+	out := []polygonio.HistoricQuotesResponse{}
+	for i := range canned {
+		q := canned[i]
+		if request.Reverse {
+			q = canned[len(canned)-1-i]
+			if request.Timestamp != 0 && q.SipUnixNano > int64(request.Timestamp) {
+				continue
+			}
+		} else if q.SipUnixNano < int64(request.Timestamp) {
+			continue
+		}
+		if request.Limit > 0 && len(out) == request.Limit {
+			break
+		}
+		out = append(out, q)
+	}
+	return out
+}
+
+func (f *Fake) HistoricTrades(ctx context.Context, request polygonio.HistoricTradesRequest) (*polygonio.HistoricTradesResponseContainer, error) {
+	if err := f.begin(ctx, Call{Method: "HistoricTrades", Request: request}); err != nil {
+		return nil, err
+	}
+	if f.HistoricTradesFunc != nil {
+		return f.HistoricTradesFunc(ctx, request)
+	}
+	canned := f.TradesResults[request.Ticker]
+	out := &polygonio.HistoricTradesResponseContainer{Results: []polygonio.HistoricTradesResponse{}}
+	for i := range canned {
+		t := canned[i]
+		if request.Reverse {
+			t = canned[len(canned)-1-i]
+			if request.Timestamp != 0 && t.SipUnixNano > int64(request.Timestamp) {
+				continue
+			}
+		} else if t.SipUnixNano < int64(request.Timestamp) {
+			continue
+		}
+		if request.Limit > 0 && len(out.Results) == request.Limit {
+			break
+		}
+		out.Results = append(out.Results, t)
+	}
+	return out, nil
+}
+
+func (f *Fake) LastQuote(ctx context.Context, request polygonio.LastQuoteRequest) (*polygonio.LastQuoteResponseContainer, error) {
+	if err := f.begin(ctx, Call{Method: "LastQuote", Request: request}); err != nil {
+		return nil, err
+	}
+	if f.LastQuoteFunc != nil {
+		return f.LastQuoteFunc(ctx, request)
+	}
+	return &polygonio.LastQuoteResponseContainer{Last: f.LastQuotes[request.Ticker]}, nil
+}
@@ -0,0 +1,166 @@
+package polygoniotest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maerlyn5/polygonio"
+	"github.com/shopspring/decimal"
+)
+
+func TestFake_Aggregates(t *testing.T) {
+
+	fake := &Fake{AggregatesResults: map[string][]polygonio.AggregatesResponse{
+		"AAPL": {
+			{UnixMiliSec: 1546297200000, Close: decimal.NewFromInt(1)}, //2018-12-31 6:00:00 PM EST
+			{UnixMiliSec: 1546419600000, Close: decimal.NewFromInt(2)}, //2019-01-02 4:00:00 AM EST
+		},
+	}}
+
+	request := polygonio.AggregatesRequest{
+		Ticker:     "AAPL",
+		Multiplier: 1,
+		Timespan:   "hour",
+		From:       time.Date(2019, 01, 01, 0, 0, 0, 0, polygonio.AmericaNewYork),
+		To:         time.Date(2019, 01, 02, 0, 0, 0, 0, polygonio.AmericaNewYork),
+	}
+
+	resp, err := fake.Aggregates(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 1 || !resp.Results[0].Close.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("Results = %v", resp.Results)
+	}
+	if got := resp.Results[0].ImpliedEnd().Sub(resp.Results[0].UnixMiliSecInTime()); got != time.Hour {
+		t.Errorf("timespan = %v, want %v", got, time.Hour)
+	}
+
+	closest, err := fake.AggregatesSearch(context.Background(), request, time.Date(2019, 01, 02, 4, 30, 0, 0, polygonio.AmericaNewYork))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(closest) != 1 || closest[0].UnixMiliSec != 1546419600000 {
+		t.Errorf("AggregatesSearch() = %v", closest)
+	}
+
+	before, err := fake.AggregatesSearchWith(context.Background(), request, time.Date(2019, 01, 01, 12, 0, 0, 0, polygonio.AmericaNewYork), polygonio.SearchOptions{Mode: polygonio.SearchAtOrBefore})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 1 || before[0].UnixMiliSec != 1546297200000 {
+		t.Errorf("AggregatesSearchWith() = %v", before)
+	}
+	_, err = fake.AggregatesSearchWith(context.Background(), request, time.Date(2019, 01, 03, 0, 0, 0, 0, polygonio.AmericaNewYork), polygonio.SearchOptions{Mode: polygonio.SearchAtOrAfter})
+	if !errors.Is(err, polygonio.SearchReturnedNoResults) {
+		t.Errorf("AggregatesSearchWith() err = %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 4 || calls[0].Method != "Aggregates" || calls[1].Method != "AggregatesSearch" || calls[2].Method != "AggregatesSearchWith" {
+		t.Errorf("Calls() = %v", calls)
+	}
+	if calls[0].Request.(polygonio.AggregatesRequest).Ticker != "AAPL" {
+		t.Errorf("Request = %v", calls[0].Request)
+	}
+}
+
+func TestFake_AggregatesNoResults(t *testing.T) {
+
+	fake := &Fake{}
+	request := polygonio.AggregatesRequest{
+		Ticker:     "AAPL",
+		Multiplier: 1,
+		Timespan:   "hour",
+		From:       time.Date(2019, 01, 01, 0, 0, 0, 0, polygonio.AmericaNewYork),
+		To:         time.Date(2019, 01, 02, 0, 0, 0, 0, polygonio.AmericaNewYork),
+	}
+
+	resp, err := fake.Aggregates(context.Background(), request)
+	if !errors.Is(err, polygonio.ErrNoResults) {
+		t.Errorf("Aggregates() = %v, %v, want ErrNoResults", resp, err)
+	}
+	resp, err = fake.AggregatesRange(context.Background(), request)
+	if err != nil || len(resp.Results) != 0 {
+		t.Errorf("AggregatesRange() = %v, %v, want no bars", resp, err)
+	}
+}
+
+func TestFake_AggregatesSearchUnsorted(t *testing.T) {
+
+	canned := []polygonio.AggregatesResponse{
+		{UnixMiliSec: 1546419600000, Close: decimal.NewFromInt(2)}, //2019-01-02 4:00:00 AM EST
+		{UnixMiliSec: 1546297200000, Close: decimal.NewFromInt(1)}, //2018-12-31 6:00:00 PM EST
+	}
+	want := append([]polygonio.AggregatesResponse(nil), canned...)
+	fake := &Fake{AggregatesResults: map[string][]polygonio.AggregatesResponse{"AAPL": canned}}
+	request := polygonio.AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "hour"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			closest, err := fake.AggregatesSearch(context.Background(), request, time.Date(2019, 01, 02, 4, 30, 0, 0, polygonio.AmericaNewYork))
+			if err != nil || len(closest) != 1 || closest[0].UnixMiliSec != 1546419600000 {
+				t.Errorf("AggregatesSearch() = %v, %v", closest, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(canned, want) {
+		t.Errorf("AggregatesSearch() changed the canned bars to %v", canned)
+	}
+}
+
+func TestFake_Errors(t *testing.T) {
+
+	errBoom := errors.New("boom")
+	fake := &Fake{Errors: map[string]error{"LastQuote": errBoom}}
+
+	if _, err := fake.LastQuote(context.Background(), polygonio.LastQuoteRequest{Ticker: "AAPL"}); err != errBoom {
+		t.Errorf("LastQuote() err = %v, want %v", err, errBoom)
+	}
+	if _, err := fake.HistoricQuotes(context.Background(), polygonio.HistoricQuotesRequest{Ticker: "AAPL"}); err != nil {
+		t.Errorf("HistoricQuotes() err = %v", err)
+	}
+
+	fake.Errors["*"] = errBoom
+	if _, err := fake.HistoricTrades(context.Background(), polygonio.HistoricTradesRequest{Ticker: "AAPL"}); err != errBoom {
+		t.Errorf("HistoricTrades() err = %v, want %v", err, errBoom)
+	}
+}
+
+func TestFake_Latency(t *testing.T) {
+
+	fake := &Fake{Latency: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := fake.LastQuote(ctx, polygonio.LastQuoteRequest{Ticker: "AAPL"}); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(fake.CallsTo("LastQuote")) != 1 {
+		t.Errorf("CallsTo() = %v", fake.CallsTo("LastQuote"))
+	}
+}
+
+func TestFake_HistoricQuotesAll(t *testing.T) {
+
+	fake := &Fake{QuotesResults: map[string][]polygonio.HistoricQuotesResponse{
+		"AAPL": {{SipUnixNano: 1}, {SipUnixNano: 2}, {SipUnixNano: 3}},
+	}}
+
+	quotes, err := fake.HistoricQuotesAll(context.Background(), polygonio.HistoricQuotesRequest{Ticker: "AAPL", Reverse: true, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 3 || quotes[0].SipUnixNano != 3 {
+		t.Errorf("HistoricQuotesAll() = %v", quotes)
+	}
+}
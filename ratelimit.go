@@ -0,0 +1,119 @@
+package polygonio
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//RateLimiter is consulted before every request that actually goes out on the network,
+//cache hits never reach it
+type RateLimiter interface {
+	//Wait blocks until a request may be made or ctx is done
+	Wait(ctx context.Context) error
+}
+
+type Plan struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+var (
+	//the free tier caps at 5 requests per minute, no burst keeps any 60s window within it
+	PlanBasic = Plan{RequestsPerMinute: 5, Burst: 1}
+	//paid tiers are unlimited but polygon asks to stay under 100 requests per second
+	PlanUnlimited = Plan{RequestsPerMinute: 6000, Burst: 100}
+)
+
+func NewPlanRateLimiter(plan Plan) *TokenBucket {
+	return NewTokenBucket(plan.RequestsPerMinute, plan.Burst)
+}
+
+//TokenBucket must be shared by pointer, every copy of a PolygonioClient holding it draws from the same bucket
+type TokenBucket struct {
+	//nil means SystemClock
+	Clock Clock
+
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+func NewTokenBucket(perMinute int, burst int) *TokenBucket {
+	if perMinute <= 0 {
+		panic("expected perMinute > 0")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+	}
+}
+
+func (tb *TokenBucket) clock() Clock {
+	if tb.Clock == nil {
+		return SystemClock{}
+	}
+	return tb.Clock
+}
+
+//reserve takes a token, possibly going into debt, and returns how long to wait for it
+func (tb *TokenBucket) reserve() time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.clock().Now()
+	if !tb.last.IsZero() {
+		tb.tokens += float64(now.Sub(tb.last)) / float64(tb.interval)
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens * float64(tb.interval))
+}
+
+func (tb *TokenBucket) cancel() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.tokens++
+}
+
+func (tb *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := wait(ctx, tb.clock(), tb.reserve()); err != nil {
+		tb.cancel()
+		return err
+	}
+	return nil
+}
+
+//RateLimitDoer returns next gated by the rate limiter
+func RateLimitDoer(limiter RateLimiter, next Doer) Doer {
+	return rateLimitDoer{limiter: limiter, next: next}
+}
+
+type rateLimitDoer struct {
+	limiter RateLimiter
+	next    Doer
+}
+
+func (rd rateLimitDoer) Do(r *http.Request) (*http.Response, error) {
+	if err := rd.limiter.Wait(r.Context()); err != nil {
+		return nil, err
+	}
+	return rd.next.Do(r)
+}
@@ -0,0 +1,126 @@
+package polygonio
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_Wait(t *testing.T) {
+
+	clock := &fakeClock{now: time.Date(2020, 4, 25, 12, 0, 0, 0, time.UTC)}
+	tb := NewTokenBucket(60, 2)
+	tb.Clock = clock
+
+	for i := 0; i < 4; i++ {
+		if err := tb.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//burst of two, then one token per second
+	if want := []time.Duration{time.Second, time.Second}; !reflect.DeepEqual(clock.waits, want) {
+		t.Errorf("waits = %v, want %v", clock.waits, want)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	clock.waits = nil
+	for i := 0; i < 2; i++ {
+		if err := tb.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.waits) != 0 {
+		t.Errorf("waits = %v, want none after refill", clock.waits)
+	}
+}
+
+func TestTokenBucket_WaitCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := &fakeClock{now: time.Date(2020, 4, 25, 12, 0, 0, 0, time.UTC), onWait: cancel}
+	tb := NewPlanRateLimiter(PlanBasic)
+	tb.Clock = clock
+
+	if err := tb.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.Wait(ctx); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	//the cancelled reservation is handed back
+	if tb.tokens != 0 {
+		t.Errorf("tokens = %v, want 0", tb.tokens)
+	}
+}
+
+type countingLimiter struct {
+	waits int
+}
+
+func (cl *countingLimiter) Wait(ctx context.Context) error {
+	cl.waits++
+	return nil
+}
+
+type staticCacher struct {
+	response string
+}
+
+func (sc staticCacher) Save(request *http.Request, response *http.Response) error {
+	return nil
+}
+
+func (sc staticCacher) Get(request *http.Request) (*http.Response, error) {
+	if sc.response == "" {
+		return nil, nil
+	}
+	return http.ReadResponse(bufio.NewReader(strings.NewReader(sc.response)), request)
+}
+
+func TestPolygonioClient_RateLimiter(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[]}`))
+	}))
+	defer server.Close()
+
+	cached := "HTTP/1.1 200 OK\r\nContent-Length: 14\r\n\r\n{\"results\":[]}"
+
+	tests := []struct {
+		name      string
+		cacher    Cacher
+		wantWaits int
+	}{
+		{name: "network", cacher: staticCacher{}, wantWaits: 1},
+		{name: "cache hit", cacher: staticCacher{response: cached}, wantWaits: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &countingLimiter{}
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{
+				HTTPClient:  server.Client(),
+				BaseHost:    u.Host,
+				BaseScheme:  u.Scheme,
+				Cacher:      tt.cacher,
+				RateLimiter: limiter,
+			}
+
+			//copies of the client share the limiter
+			copied := pc
+			if _, err := copied.Aggregates(context.Background(), AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "day"}); err != nil {
+				t.Fatal(err)
+			}
+			if limiter.waits != tt.wantWaits {
+				t.Errorf("waits = %v, want %v", limiter.waits, tt.wantWaits)
+			}
+		})
+	}
+}
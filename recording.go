@@ -0,0 +1,151 @@
+package polygonio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+type CassetteMode int
+
+const (
+	//serve every request from a cassette, the zero value so tests are hermetic by default
+	CassetteReplay CassetteMode = iota
+	//make every request and (over)write its cassette
+	CassetteRecord
+	//make every request, touch no cassette
+	CassettePassthrough
+)
+
+//ErrCassetteMissing is wrapped by the error returned in replay mode for a request that was never recorded
+var ErrCassetteMissing = fmt.Errorf("cassette missing")
+
+//RecordingTransport records request/response pairs into cassette files and replays them.
+//Cassettes live where FileCacher would put the same request, with the apiKey scrubbed,
+//but every method and status is recorded
+type RecordingTransport struct {
+	Mode CassetteMode
+	Dir  string
+	//nil means http.DefaultTransport
+	Next http.RoundTripper
+	//nil means OsFileCacherIo
+	FileCacherIo FileCacherIo
+}
+
+func (rt RecordingTransport) next() http.RoundTripper {
+	if rt.Next == nil {
+		return http.DefaultTransport
+	}
+	return rt.Next
+}
+
+func (rt RecordingTransport) fileCacherIo() FileCacherIo {
+	if rt.FileCacherIo == nil {
+		return OsFileCacherIo{}
+	}
+	return rt.FileCacherIo
+}
+
+//CassettePath is where the cassette for request is stored
+func (rt RecordingTransport) CassettePath(request *http.Request) (dir string, fn string) {
+	dir, fn = FileCacher{Dir: rt.Dir}.FilePath(request)
+	if request.Method != "GET" {
+		fn = request.Method + "-" + fn
+	}
+	return dir, fn
+}
+
+func (rt RecordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	switch rt.Mode {
+	case CassetteReplay:
+		return rt.replay(r)
+	case CassetteRecord:
+		return rt.record(r)
+	}
+	return rt.next().RoundTrip(r)
+}
+
+func (rt RecordingTransport) replay(r *http.Request) (*http.Response, error) {
+	dir, fn := rt.CassettePath(r)
+	f, err := rt.fileCacherIo().Read(filepath.Join(dir, fn))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s %s", ErrCassetteMissing, r.Method, RequestKey(r))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(b))
+	//the recorded request is only there for people reading the cassette
+	recorded, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt cassette %s: %w", filepath.Join(dir, fn), err)
+	}
+	io.Copy(ioutil.Discard, recorded.Body)
+	return http.ReadResponse(reader, r)
+}
+
+func (rt RecordingTransport) record(r *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := rt.next().RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	scrubbed := r.Clone(r.Context())
+	u := *r.URL
+	q := u.Query()
+	if q.Get("apiKey") != "" {
+		q.Set("apiKey", "X")
+	}
+	u.RawQuery = q.Encode()
+	scrubbed.URL = &u
+	scrubbed.Header.Del("Authorization")
+	scrubbed.Body = nil
+	if len(reqBody) > 0 {
+		scrubbed.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		scrubbed.ContentLength = int64(len(reqBody))
+	}
+
+	dir, fn := rt.CassettePath(r)
+	err = rt.fileCacherIo().AtomicWrite(dir, fn, func(w io.Writer) error {
+		if err := scrubbed.Write(w); err != nil {
+			return err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		return resp.Write(w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
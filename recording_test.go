@@ -0,0 +1,89 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingTransport(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "cassettes-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/v1/last_quote/stocks/MSFT" {
+			w.WriteHeader(404)
+			w.Write([]byte(`{"status":"NOT_FOUND"}`))
+			return
+		}
+		w.Write([]byte(`{"results":[{"o":1,"c":2,"h":3,"l":0.5,"v":100,"t":1546419600000,"n":1}]}`))
+	}))
+	u, _ := url.Parse(server.URL)
+
+	client := func(mode CassetteMode) PolygonioClient {
+		return PolygonioClient{
+			HTTPClient: &http.Client{Transport: RecordingTransport{Mode: mode, Dir: dir, Next: server.Client().Transport}},
+			APIKey:     "secret",
+			BaseHost:   u.Host,
+			BaseScheme: u.Scheme,
+		}
+	}
+	request := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "hour", From: time.Date(2019, 01, 02, 0, 0, 0, 0, time.UTC), To: time.Date(2019, 01, 02, 0, 0, 0, 0, time.UTC)}
+
+	recorded, err := client(CassetteRecord).Aggregates(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client(CassetteRecord).LastQuote(context.Background(), LastQuoteRequest{Ticker: "MSFT"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrNotFound)
+	}
+	server.Close()
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if strings.Contains(string(b), "secret") || strings.Contains(path, "secret") {
+			t.Errorf("apiKey not scrubbed from %v", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := client(CassetteReplay).Aggregates(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed.Results) != 1 || !replayed.Results[0].Close.Equal(recorded.Results[0].Close) {
+		t.Errorf("replayed = %v, want %v", replayed.Results, recorded.Results)
+	}
+	if _, err := client(CassetteReplay).LastQuote(context.Background(), LastQuoteRequest{Ticker: "MSFT"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want the recorded %v", err, ErrNotFound)
+	}
+
+	_, err = client(CassetteReplay).LastQuote(context.Background(), LastQuoteRequest{Ticker: "AAPL"})
+	if !errors.Is(err, ErrCassetteMissing) || !strings.Contains(err.Error(), "/v1/last_quote/stocks/AAPL") {
+		t.Errorf("err = %v, want %v naming the request", err, ErrCassetteMissing)
+	}
+
+	if calls != 2 {
+		t.Errorf("calls = %v, want 2", calls)
+	}
+}
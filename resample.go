@@ -0,0 +1,114 @@
+package polygonio
+
+import (
+	"sort"
+	"time"
+)
+
+//Anchor decides where Resample buckets start, every anchor works in AmericaNewYork
+type Anchor int
+
+const (
+	//buckets count from 9:30 each day, bars before the open fill buckets counted back from it
+	AnchorSessionOpen Anchor = iota
+	AnchorMidnight
+	//buckets count from monday midnight
+	AnchorWeek
+	//buckets count from midnight on the first of the month
+	AnchorMonth
+)
+
+//period is the day, week or month holding t and where buckets count from within it
+func (a Anchor) period(t time.Time) (origin time.Time, start time.Time, end time.Time) {
+	t = t.In(AmericaNewYork)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, AmericaNewYork)
+	switch a {
+	case AnchorSessionOpen:
+		return time.Date(t.Year(), t.Month(), t.Day(), 9, 30, 0, 0, AmericaNewYork), day, day.AddDate(0, 0, 1)
+	case AnchorWeek:
+		//time.Sunday is 0
+		monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return monday, monday, monday.AddDate(0, 0, 7)
+	case AnchorMonth:
+		first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, AmericaNewYork)
+		return first, first, first.AddDate(0, 1, 0)
+	}
+	return day, day, day.AddDate(0, 0, 1)
+}
+
+//bucket is the [start, end) of the bucket holding t, buckets never cross a period boundary
+//so the last one of a day, week or month may be short
+func (a Anchor) bucket(t time.Time, target time.Duration) (time.Time, time.Time) {
+	origin, start, end := a.period(t)
+	if target <= 0 {
+		return start, end
+	}
+
+	var bucketStart, bucketEnd time.Time
+	if target%(24*time.Hour) == 0 {
+		//whole days step on the calendar, a day across a dst change is 23 or 25 hours
+		days := int(target / (24 * time.Hour))
+		n := calendarDays(origin, t)
+		k := n / days
+		if n < 0 && n%days != 0 {
+			k--
+		}
+		bucketStart = origin.AddDate(0, 0, k*days)
+		bucketEnd = bucketStart.AddDate(0, 0, days)
+	} else {
+		offset := t.Sub(origin)
+		k := offset / target
+		if offset < 0 && offset%target != 0 {
+			k--
+		}
+		bucketStart = origin.Add(k * target)
+		bucketEnd = bucketStart.Add(target)
+	}
+	if bucketStart.Before(start) {
+		bucketStart = start
+	}
+	if bucketEnd.After(end) {
+		bucketEnd = end
+	}
+	return bucketStart, bucketEnd
+}
+
+//calendarDays is how many whole calendar days t is after origin, negative before it
+func calendarDays(origin time.Time, t time.Time) int {
+	t = t.In(AmericaNewYork)
+	n := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Sub(time.Date(origin.Year(), origin.Month(), origin.Day(), 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	if t.Before(origin.AddDate(0, 0, n)) {
+		n--
+	}
+	return n
+}
+
+//Resample merges bars into buckets of target aligned to anchor, AnchorSessionOpen unless given.
+//A target of 0 makes one bucket per day, week or month. Buckets without bars are left out, the
+//bars of a partly filled bucket still merge into a bar as long as the whole bucket
+func Resample(bars []AggregatesResponse, target time.Duration, anchor ...Anchor) []AggregatesResponse {
+	a := AnchorSessionOpen
+	if len(anchor) > 0 {
+		a = anchor[0]
+	}
+
+	sorted := append([]AggregatesResponse(nil), bars...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].UnixMiliSec < sorted[j].UnixMiliSec })
+
+	out := []AggregatesResponse{}
+	for i := 0; i < len(sorted); {
+		start, end := a.bucket(sorted[i].UnixMiliSecInTime(), target)
+		j := i + 1
+		for j < len(sorted) && sorted[j].UnixMiliSecInTime().Before(end) {
+			j++
+		}
+
+		merged := Merge(sorted[i:j])
+		merged.UnixMiliSec = start.UnixNano() / int64(time.Millisecond)
+		merged.timespan = ""
+		merged.timespanDuration = end.Sub(start)
+		out = append(out, merged)
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package polygonio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestResample(t *testing.T) {
+
+	ny := func(month time.Month, day int, hour int, min int) time.Time {
+		return time.Date(2020, month, day, hour, min, 0, 0, AmericaNewYork)
+	}
+	//minute bar at start, price rising with i
+	bar := func(start time.Time, i int64) AggregatesResponse {
+		return AggregatesResponse{
+			UnixMiliSec:      start.UnixNano() / int64(time.Millisecond),
+			Open:             decimal.NewFromInt(i),
+			Close:            decimal.NewFromInt(i + 1),
+			High:             decimal.NewFromInt(i + 2),
+			Low:              decimal.NewFromInt(i - 1),
+			Volume:           decimal.NewFromInt(10),
+			VWAP:             decimal.NewFromInt(i + 1),
+			N:                1,
+			timespanDuration: time.Minute,
+		}
+	}
+	minutes := func(from time.Time, n int) []AggregatesResponse {
+		out := []AggregatesResponse{}
+		for i := 0; i < n; i++ {
+			out = append(out, bar(from.Add(time.Duration(i)*time.Minute), int64(i)))
+		}
+		return out
+	}
+
+	type want struct {
+		start    time.Time
+		duration time.Duration
+		n        int64
+	}
+	tests := []struct {
+		name   string
+		bars   []AggregatesResponse
+		target time.Duration
+		anchor []Anchor
+		want   []want
+	}{
+		{
+			name:   "5 minute bars from the open",
+			bars:   minutes(ny(4, 24, 9, 30), 15),
+			target: 5 * time.Minute,
+			want:   []want{{ny(4, 24, 9, 30), 5 * time.Minute, 5}, {ny(4, 24, 9, 35), 5 * time.Minute, 5}, {ny(4, 24, 9, 40), 5 * time.Minute, 5}},
+		},
+		{
+			name:   "gaps are left out",
+			bars:   []AggregatesResponse{bar(ny(4, 24, 9, 31), 0), bar(ny(4, 24, 9, 50), 1), bar(ny(4, 24, 9, 30), 2)},
+			target: 5 * time.Minute,
+			want:   []want{{ny(4, 24, 9, 30), 5 * time.Minute, 2}, {ny(4, 24, 9, 50), 5 * time.Minute, 1}},
+		},
+		{
+			name:   "390 minute bars around the session",
+			bars:   []AggregatesResponse{bar(ny(4, 24, 4, 0), 0), bar(ny(4, 24, 9, 30), 1), bar(ny(4, 24, 15, 59), 2), bar(ny(4, 24, 16, 0), 3), bar(ny(4, 24, 19, 59), 4)},
+			target: 390 * time.Minute,
+			want:   []want{{ny(4, 24, 3, 0), 390 * time.Minute, 1}, {ny(4, 24, 9, 30), 390 * time.Minute, 2}, {ny(4, 24, 16, 0), 390 * time.Minute, 2}},
+		},
+		{
+			name:   "last bucket of the day is short",
+			bars:   []AggregatesResponse{bar(ny(4, 24, 23, 0), 0)},
+			target: 390 * time.Minute,
+			want:   []want{{ny(4, 24, 22, 30), 90 * time.Minute, 1}},
+		},
+		{
+			name:   "daily across dst",
+			bars:   []AggregatesResponse{bar(ny(3, 7, 12, 0), 0), bar(ny(3, 8, 12, 0), 1), bar(ny(3, 8, 13, 0), 2)},
+			anchor: []Anchor{AnchorMidnight},
+			want:   []want{{ny(3, 7, 0, 0), 24 * time.Hour, 1}, {ny(3, 8, 0, 0), 23 * time.Hour, 2}},
+		},
+		{
+			name:   "hourly from midnight",
+			bars:   minutes(ny(4, 24, 0, 30), 60),
+			target: time.Hour,
+			anchor: []Anchor{AnchorMidnight},
+			want:   []want{{ny(4, 24, 0, 0), time.Hour, 30}, {ny(4, 24, 1, 0), time.Hour, 30}},
+		},
+		{
+			name:   "weekly",
+			bars:   []AggregatesResponse{bar(ny(4, 20, 10, 0), 0), bar(ny(4, 24, 10, 0), 1), bar(ny(4, 27, 10, 0), 2)},
+			anchor: []Anchor{AnchorWeek},
+			want:   []want{{ny(4, 20, 0, 0), 7 * 24 * time.Hour, 2}, {ny(4, 27, 0, 0), 7 * 24 * time.Hour, 1}},
+		},
+		{
+			name:   "daily buckets of a month after dst ends",
+			bars:   []AggregatesResponse{bar(ny(11, 1, 10, 0), 0), bar(ny(11, 1, 23, 30), 1), bar(ny(11, 2, 0, 30), 2)},
+			target: 24 * time.Hour,
+			anchor: []Anchor{AnchorMonth},
+			//november 1st gains an hour
+			want: []want{{ny(11, 1, 0, 0), 25 * time.Hour, 2}, {ny(11, 2, 0, 0), 24 * time.Hour, 1}},
+		},
+		{
+			name:   "weekly buckets of a month across dst",
+			bars:   []AggregatesResponse{bar(ny(3, 1, 10, 0), 0), bar(ny(3, 7, 23, 30), 1), bar(ny(3, 8, 0, 30), 2), bar(ny(3, 15, 0, 30), 3)},
+			target: 7 * 24 * time.Hour,
+			anchor: []Anchor{AnchorMonth},
+			want:   []want{{ny(3, 1, 0, 0), 7 * 24 * time.Hour, 2}, {ny(3, 8, 0, 0), 7*24*time.Hour - time.Hour, 1}, {ny(3, 15, 0, 0), 7 * 24 * time.Hour, 1}},
+		},
+		{
+			name:   "monthly",
+			bars:   []AggregatesResponse{bar(ny(3, 2, 10, 0), 0), bar(ny(3, 31, 10, 0), 1), bar(ny(4, 1, 10, 0), 2)},
+			anchor: []Anchor{AnchorMonth},
+			//march loses an hour to dst
+			want: []want{{ny(3, 1, 0, 0), 31*24*time.Hour - time.Hour, 2}, {ny(4, 1, 0, 0), 30 * 24 * time.Hour, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resample(tt.bars, tt.target, tt.anchor...)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Resample() = %v bars, want %v: %v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if !got[i].UnixMiliSecInTime().Equal(w.start) || got[i].timespanDuration != w.duration || got[i].N != w.n {
+					t.Errorf("bar %v = %v n:%v, want %v (%v) n:%v", i, got[i], got[i].N, w.start.Format(StringFormat), w.duration, w.n)
+				}
+			}
+		})
+	}
+
+	//ohlcv of the first 5 minute bar of minutes(9:30, 15)
+	got := Resample(minutes(ny(4, 24, 9, 30), 15), 5*time.Minute)[0]
+	for name, pair := range map[string][2]decimal.Decimal{
+		"open":   {got.Open, decimal.NewFromInt(0)},
+		"close":  {got.Close, decimal.NewFromInt(5)},
+		"high":   {got.High, decimal.NewFromInt(6)},
+		"low":    {got.Low, decimal.NewFromInt(-1)},
+		"volume": {got.Volume, decimal.NewFromInt(50)},
+		"vwap":   {got.VWAP, decimal.NewFromInt(3)},
+	} {
+		if !pair[0].Equal(pair[1]) {
+			t.Errorf("%v = %v, want %v", name, pair[0], pair[1])
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	if got := Merge(nil); got.UnixMiliSec != 0 || !got.Volume.IsZero() {
+		t.Errorf("Merge(nil) = %v, want the zero value", got)
+	}
+
+	start := time.Date(2020, 4, 24, 9, 30, 0, 0, AmericaNewYork)
+	in := []AggregatesResponse{}
+	for i := int64(0); i < 3; i++ {
+		in = append(in, AggregatesResponse{
+			UnixMiliSec:      start.Add(time.Duration(i)*time.Minute).UnixNano() / int64(time.Millisecond),
+			Open:             decimal.NewFromInt(i),
+			Close:            decimal.NewFromInt(i),
+			High:             decimal.NewFromInt(i),
+			Low:              decimal.NewFromInt(i),
+			N:                2,
+			timespanDuration: time.Minute,
+		})
+	}
+	got := Merge(in)
+	if !got.Open.Equal(decimal.NewFromInt(0)) || !got.Close.Equal(decimal.NewFromInt(2)) || got.N != 6 || got.timespanDuration != 3*time.Minute {
+		t.Errorf("Merge() = %v n:%v", got, got.N)
+	}
+}
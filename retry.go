@@ -0,0 +1,198 @@
+package polygonio
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//Clock is the time source for anything that waits, tests swap it for a fake
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+var DefaultRetryableStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     4,
+	BaseDelay:       500 * time.Millisecond,
+	MaxDelay:        30 * time.Second,
+	Jitter:          0.2,
+	RetryableStatus: DefaultRetryableStatus,
+}
+
+type RetryPolicy struct {
+	//total tries including the first one, <= 1 disables retrying
+	MaxAttempts int
+	//delay before the second attempt, doubled for every attempt after that
+	BaseDelay time.Duration
+	//upper bound for any single backoff. A Retry-After asking for longer is not retried, the response is returned
+	MaxDelay time.Duration
+	//fraction of the delay that is randomized, 0.2 waits somewhere in [0.8d, 1.2d]
+	Jitter float64
+	//nil means DefaultRetryableStatus
+	RetryableStatus []int
+	//nil retries every transport error as long as the request context is alive
+	RetryableError func(err error) bool
+	//nil means SystemClock
+	Clock Clock
+}
+
+func (rp RetryPolicy) clock() Clock {
+	if rp.Clock == nil {
+		return SystemClock{}
+	}
+	return rp.Clock
+}
+
+func (rp RetryPolicy) retryableStatus(code int) bool {
+	statuses := rp.RetryableStatus
+	if statuses == nil {
+		statuses = DefaultRetryableStatus
+	}
+	for _, s := range statuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (rp RetryPolicy) retryableError(err error) bool {
+	if rp.RetryableError == nil {
+		return true
+	}
+	return rp.RetryableError(err)
+}
+
+//Delay is the backoff before attempt+1, attempt starts at 1
+func (rp RetryPolicy) Delay(attempt int) time.Duration {
+	d := rp.BaseDelay
+	for i := 1; i < attempt && (rp.MaxDelay <= 0 || d < rp.MaxDelay); i++ {
+		d *= 2
+	}
+	if rp.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * rp.Jitter * float64(d))
+	}
+	return rp.clamp(d)
+}
+
+func (rp RetryPolicy) clamp(d time.Duration) time.Duration {
+	if rp.MaxDelay > 0 && d > rp.MaxDelay {
+		return rp.MaxDelay
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+//retryAfter parses both forms of the header, delay-seconds and http-date
+func (rp RetryPolicy) retryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return nonNegative(time.Duration(seconds) * time.Second), true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return nonNegative(at.Sub(rp.clock().Now())), true
+	}
+	return 0, false
+}
+
+//Doer returns next wrapped with the retry policy
+func (rp RetryPolicy) Doer(next Doer) Doer {
+	return retryDoer{policy: rp, next: next}
+}
+
+type retryDoer struct {
+	policy RetryPolicy
+	next   Doer
+}
+
+func (rd retryDoer) Do(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := rd.next.Do(r)
+
+		last := attempt >= rd.policy.MaxAttempts || (r.Body != nil && r.GetBody == nil)
+		if err != nil {
+			if last || ctx.Err() != nil || !rd.policy.retryableError(err) {
+				return nil, err
+			}
+		} else if last || !rd.policy.retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay := rd.policy.Delay(attempt)
+		if resp != nil {
+			if after, ok := rd.policy.retryAfter(resp); ok {
+				//retrying before the server allows it only burns attempts
+				if rd.policy.MaxDelay > 0 && after > rd.policy.MaxDelay {
+					return resp, nil
+				}
+				delay = after
+			}
+			//drain so the connection can be reused, the failed attempt is never cached
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := wait(ctx, rd.policy.clock(), delay); err != nil {
+			return nil, err
+		}
+
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+	}
+}
+
+func wait(ctx context.Context, clock Clock, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,204 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now    time.Time
+	waits  []time.Duration
+	onWait func()
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) After(d time.Duration) <-chan time.Time {
+	fc.waits = append(fc.waits, d)
+	fc.now = fc.now.Add(d)
+	if fc.onWait != nil {
+		fc.onWait()
+	}
+	c := make(chan time.Time, 1)
+	c <- fc.now
+	return c
+}
+
+type countingCacher struct {
+	saves int
+}
+
+func (cc *countingCacher) Save(request *http.Request, response *http.Response) error {
+	cc.saves++
+	return nil
+}
+
+func (cc *countingCacher) Get(request *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	rp := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := rp.Delay(i + 1); got != w {
+			t.Errorf("RetryPolicy.Delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestPolygonioClient_Retry(t *testing.T) {
+
+	now := time.Date(2020, 4, 25, 12, 0, 0, 0, time.UTC)
+
+	type reply struct {
+		status     int
+		retryAfter string
+	}
+	tests := []struct {
+		name        string
+		replies     []reply
+		wantErr     error
+		wantCalls   int
+		wantWaits   []time.Duration
+		wantSaves   int
+		maxAttempts int
+	}{
+		{
+			name:      "recovers",
+			replies:   []reply{{status: 503}, {status: 500}, {status: 200}},
+			wantCalls: 3,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second},
+			wantSaves: 1,
+		},
+		{
+			name:      "retry-after seconds",
+			replies:   []reply{{status: 429, retryAfter: "7"}, {status: 200}},
+			wantCalls: 2,
+			wantWaits: []time.Duration{7 * time.Second},
+			wantSaves: 1,
+		},
+		{
+			name:      "retry-after date",
+			replies:   []reply{{status: 429, retryAfter: now.Add(3 * time.Second).Format(http.TimeFormat)}, {status: 200}},
+			wantCalls: 2,
+			wantWaits: []time.Duration{3 * time.Second},
+			wantSaves: 1,
+		},
+		{
+			name:      "retry-after past max delay",
+			replies:   []reply{{status: 429, retryAfter: "120"}, {status: 200}},
+			wantErr:   StatusError(429),
+			wantCalls: 1,
+		},
+		{
+			name:      "retry-after date past max delay",
+			replies:   []reply{{status: 429, retryAfter: now.Add(time.Hour).Format(http.TimeFormat)}, {status: 200}},
+			wantErr:   StatusError(429),
+			wantCalls: 1,
+		},
+		{
+			name:      "not retryable",
+			replies:   []reply{{status: 404}},
+			wantErr:   StatusError(404),
+			wantCalls: 1,
+		},
+		{
+			name:      "exhausted",
+			replies:   []reply{{status: 502}, {status: 502}, {status: 502}, {status: 502}},
+			wantErr:   StatusError(502),
+			wantCalls: 4,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:        "disabled",
+			replies:     []reply{{status: 502}},
+			wantErr:     StatusError(502),
+			wantCalls:   1,
+			maxAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reply := tt.replies[calls]
+				calls++
+				if reply.retryAfter != "" {
+					w.Header().Set("Retry-After", reply.retryAfter)
+				}
+				w.WriteHeader(reply.status)
+				w.Write([]byte(`{"results":[]}`))
+			}))
+			defer server.Close()
+
+			clock := &fakeClock{now: now}
+			cacher := &countingCacher{}
+			maxAttempts := tt.maxAttempts
+			if maxAttempts == 0 {
+				maxAttempts = 4
+			}
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{
+				HTTPClient: server.Client(),
+				APIKey:     "apiKey",
+				BaseHost:   u.Host,
+				BaseScheme: u.Scheme,
+				Cacher:     cacher,
+				Retry:      &RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Clock: clock},
+			}
+
+			_, err := pc.Aggregates(context.Background(), AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "day"})
+			if (tt.wantErr == nil) != (err == nil) || (err != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(clock.waits, tt.wantWaits) {
+				t.Errorf("waits = %v, want %v", clock.waits, tt.wantWaits)
+			}
+			if cacher.saves != tt.wantSaves {
+				t.Errorf("saves = %v, want %v", cacher.saves, tt.wantSaves)
+			}
+		})
+	}
+}
+
+func TestPolygonioClient_RetryCancelled(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := &fakeClock{onWait: cancel}
+
+	u, _ := url.Parse(server.URL)
+	pc := PolygonioClient{
+		HTTPClient: server.Client(),
+		BaseHost:   u.Host,
+		BaseScheme: u.Scheme,
+		Retry:      &RetryPolicy{MaxAttempts: 4, BaseDelay: time.Hour, Clock: clock},
+	}
+
+	_, err := pc.LastQuote(ctx, LastQuoteRequest{Ticker: "AAPL"})
+	if err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %v, want 1", calls)
+	}
+}
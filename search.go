@@ -0,0 +1,262 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maerlyn5/polygonio/calendar"
+)
+
+//match with errors.Is, every *SearchError is SearchReturnedNoResults and LimitExceededError once it ran out of lookback
+var LimitExceededError = fmt.Errorf("Search limit exceeded")
+var SearchReturnedNoResults = fmt.Errorf("Search returned no results")
+
+//how far AggregatesSearch looks from the searched day unless SearchOptions.Lookback says otherwise,
+//long enough for any cluster of market holidays
+const DefaultSearchLookback = 14 * 24 * time.Hour
+
+//TradingCalendar tells AggregatesSearch which days can have bars
+type TradingCalendar interface {
+	//day is any time on the day in AmericaNewYork
+	IsTradingDay(day time.Time) bool
+}
+
+//WeekdayCalendar trades monday to friday and knows no holidays, calendar.USEquities is the default
+type WeekdayCalendar struct{}
+
+func (WeekdayCalendar) IsTradingDay(day time.Time) bool {
+	switch day.In(AmericaNewYork).Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return true
+}
+
+type SearchMode int
+
+const (
+	//the bar containing search, otherwise the bars either side of it, what AggregatesSearch always did
+	SearchNearest SearchMode = iota
+	//only the bar containing search
+	SearchContaining
+	//the last bar starting at or before search
+	SearchAtOrBefore
+	//the first bar starting at or after search
+	SearchAtOrAfter
+	//the last bar starting at or before search and the first bar starting after it
+	SearchBracketing
+)
+
+func (sm SearchMode) String() string {
+	switch sm {
+	case SearchNearest:
+		return "nearest"
+	case SearchContaining:
+		return "containing"
+	case SearchAtOrBefore:
+		return "at or before"
+	case SearchAtOrAfter:
+		return "at or after"
+	case SearchBracketing:
+		return "bracketing"
+	}
+	return fmt.Sprintf("SearchMode(%d)", int(sm))
+}
+
+type SearchOptions struct {
+	Mode SearchMode
+	//how far windows may reach from the searched day, 0 means DefaultSearchLookback
+	Lookback time.Duration
+}
+
+//SearchError describes a search that found nothing
+type SearchError struct {
+	Ticker   string
+	Search   time.Time
+	Mode     SearchMode
+	Lookback time.Duration
+	//every window requested, in order
+	Windows []AggregatesRequest
+	//the lookback ran out, otherwise the bars did not satisfy the mode or the search reached today
+	LookbackExceeded bool
+}
+
+func (se *SearchError) Error() string {
+	windows := []string{}
+	for _, w := range se.Windows {
+		windows = append(windows, DateFormat(w.From)+"/"+DateFormat(w.To))
+	}
+	reason := "no results"
+	if se.LookbackExceeded {
+		reason = "lookback of " + se.Lookback.String() + " exceeded"
+	}
+	return fmt.Sprintf("AggregatesSearch %s %s %s: %s, tried %s", se.Ticker, se.Mode, se.Search.Format(StringFormat), reason, strings.Join(windows, " "))
+}
+
+func (se *SearchError) Is(target error) bool {
+	return target == SearchReturnedNoResults || (target == LimitExceededError && se.LookbackExceeded)
+}
+
+//searchBars answers mode from bars sorted by start, missing says which side needs more bars first
+func searchBars(bars []AggregatesResponse, search time.Time, mode SearchMode) (out []AggregatesResponse, missingBefore bool, missingAfter bool) {
+	//first bar starting after search
+	after := sort.Search(len(bars), func(i int) bool { return bars[i].UnixMiliSecInTime().After(search) })
+	//first bar starting at or after search
+	atOrAfter := sort.Search(len(bars), func(i int) bool { return !bars[i].UnixMiliSecInTime().Before(search) })
+	hasBefore := after > 0
+
+	switch mode {
+	case SearchNearest:
+		if !hasBefore {
+			return nil, true, false
+		}
+		if bars[after-1].Contains(search) {
+			return bars[after-1 : after], false, false
+		}
+		if after == len(bars) {
+			return nil, false, true
+		}
+		return bars[after-1 : after+1], false, false
+	case SearchContaining:
+		if !hasBefore {
+			return nil, true, false
+		}
+		if bars[after-1].Contains(search) {
+			return bars[after-1 : after], false, false
+		}
+		return nil, false, false
+	case SearchAtOrBefore:
+		if !hasBefore {
+			return nil, true, false
+		}
+		return bars[after-1 : after], false, false
+	case SearchAtOrAfter:
+		if atOrAfter == len(bars) {
+			return nil, false, true
+		}
+		return bars[atOrAfter : atOrAfter+1], false, false
+	case SearchBracketing:
+		if !hasBefore {
+			return nil, true, false
+		}
+		if after == len(bars) {
+			return nil, false, true
+		}
+		return bars[after-1 : after+1], false, false
+	}
+	return nil, false, false
+}
+
+//SearchAggregates answers mode from bars sorted by start, without fetching more
+func SearchAggregates(bars []AggregatesResponse, search time.Time, mode SearchMode) ([]AggregatesResponse, bool) {
+	out, _, _ := searchBars(bars, search, mode)
+	return out, out != nil
+}
+
+func (pc PolygonioClient) calendar() TradingCalendar {
+	if pc.Calendar == nil {
+		return calendar.USEquities{}
+	}
+	return pc.Calendar
+}
+
+//AggregatesSearch is AggregatesSearchWith in SearchNearest mode
+func (pc PolygonioClient) AggregatesSearch(ctx context.Context, request AggregatesRequest, search time.Time) ([]AggregatesResponse, error) {
+	return pc.AggregatesSearchWith(ctx, request, search, SearchOptions{})
+}
+
+//AggregatesSearchWith requests windows of trading days outward from the day of search, older ones while the mode
+//needs a bar before search and newer ones while it needs one after, until the lookback runs out.
+//request.From and To are ignored, windows are as wide as a bar of request so one always holds a whole bar
+func (pc PolygonioClient) AggregatesSearchWith(ctx context.Context, request AggregatesRequest, search time.Time, options SearchOptions) ([]AggregatesResponse, error) {
+	if err := request.Timespan.validate(); err != nil {
+		return nil, err
+	}
+	lookback := options.Lookback
+	if lookback <= 0 {
+		lookback = DefaultSearchLookback
+	}
+	span := int((request.TimespanDuration() + 24*time.Hour - 1) / (24 * time.Hour))
+	if span < 1 {
+		span = 1
+	}
+	cal := pc.calendar()
+	searchDay := calendar.Day(search)
+	today := calendar.Day(pc.now())
+	searchErr := &SearchError{Ticker: request.Ticker, Search: search, Mode: options.Mode, Lookback: lookback}
+
+	bars := []AggregatesResponse{}
+	seen := map[int64]bool{}
+	fetch := func(from time.Time, to time.Time) error {
+		window := request
+		window.From, window.To = from, to
+		searchErr.Windows = append(searchErr.Windows, window)
+		resp, err := pc.Aggregates(ctx, window)
+		if errors.Is(err, ErrNoResults) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, bar := range resp.Results {
+			if !seen[bar.UnixMiliSec] {
+				seen[bar.UnixMiliSec] = true
+				bars = append(bars, bar)
+			}
+		}
+		sort.Slice(bars, func(i, j int) bool { return bars[i].UnixMiliSec < bars[j].UnixMiliSec })
+		return nil
+	}
+
+	//the next window to request on either side, both start on the searched day
+	beforeTo, afterFrom := searchDay, searchDay
+	for {
+		out, missingBefore, missingAfter := searchBars(bars, search, options.Mode)
+		if out != nil {
+			return out, nil
+		}
+		if !missingBefore && !missingAfter {
+			return nil, searchErr
+		}
+
+		if missingBefore {
+			for !cal.IsTradingDay(beforeTo) && searchDay.Sub(beforeTo) <= lookback {
+				beforeTo = beforeTo.AddDate(0, 0, -1)
+			}
+			if searchDay.Sub(beforeTo) > lookback {
+				searchErr.LookbackExceeded = true
+				return nil, searchErr
+			}
+			from := beforeTo.AddDate(0, 0, 1-span)
+			if err := fetch(from, beforeTo); err != nil {
+				return nil, err
+			}
+			//the window after search starts with the same day, no need to request it twice
+			if afterFrom.Equal(searchDay) && !beforeTo.Before(searchDay) {
+				afterFrom = searchDay.AddDate(0, 0, 1)
+			}
+			beforeTo = from.AddDate(0, 0, -1)
+			continue
+		}
+
+		for !cal.IsTradingDay(afterFrom) && afterFrom.Sub(searchDay) <= lookback && !afterFrom.After(today) {
+			afterFrom = afterFrom.AddDate(0, 0, 1)
+		}
+		if afterFrom.After(today) {
+			return nil, searchErr
+		}
+		if afterFrom.Sub(searchDay) > lookback {
+			searchErr.LookbackExceeded = true
+			return nil, searchErr
+		}
+		to := afterFrom.AddDate(0, 0, span-1)
+		if err := fetch(afterFrom, to); err != nil {
+			return nil, err
+		}
+		afterFrom = to.AddDate(0, 0, 1)
+	}
+}
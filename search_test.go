@@ -0,0 +1,173 @@
+package polygonio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+//holidayCalendar is WeekdayCalendar without the listed days
+type holidayCalendar map[string]bool
+
+func (hc holidayCalendar) IsTradingDay(day time.Time) bool {
+	return WeekdayCalendar{}.IsTradingDay(day) && !hc[DateFormat(day.In(AmericaNewYork))]
+}
+
+//barsServer answers aggregates requests from bars like polygon, without a results key when none match
+func barsServer(bars []AggregatesResponse, windows *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//v2/aggs/ticker/{ticker}/range/{multiplier}/{timespan}/{from}/{to}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		*windows = append(*windows, parts[7]+"/"+parts[8])
+		from, _ := time.ParseInLocation("2006-01-02", parts[7], AmericaNewYork)
+		to, _ := time.ParseInLocation("2006-01-02", parts[8], AmericaNewYork)
+		to = to.AddDate(0, 0, 1)
+
+		out := map[string]interface{}{"status": "OK"}
+		results := []AggregatesResponse{}
+		for _, bar := range bars {
+			if start := bar.UnixMiliSecInTime(); !start.Before(from) && start.Before(to) {
+				results = append(results, bar)
+			}
+		}
+		if len(results) > 0 {
+			out["results"] = results
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func TestPolygonioClient_AggregatesSearchWith(t *testing.T) {
+
+	at := func(day int, hour int, min int) time.Time {
+		return time.Date(2020, 4, day, hour, min, 0, 0, AmericaNewYork)
+	}
+	//hourly bars 9:30 to 15:30 on every trading day of april 2020, good friday is the 10th
+	calendar := holidayCalendar{"2020-04-10": true}
+	bars := []AggregatesResponse{}
+	for d := 1; d <= 30; d++ {
+		if !calendar.IsTradingDay(at(d, 0, 0)) {
+			continue
+		}
+		for h := 9; h <= 15; h++ {
+			bars = append(bars, AggregatesResponse{UnixMiliSec: at(d, h, 30).UnixNano() / int64(time.Millisecond)})
+		}
+	}
+
+	tests := []struct {
+		name        string
+		search      time.Time
+		options     SearchOptions
+		want        []time.Time
+		wantWindows []string
+		wantErr     error
+	}{
+		{
+			name:        "nearest over a weekend",
+			search:      at(25, 12, 0),
+			want:        []time.Time{at(24, 15, 30), at(27, 9, 30)},
+			wantWindows: []string{"2020-04-24/2020-04-24", "2020-04-27/2020-04-27"},
+		},
+		{
+			name:        "nearest inside a bar",
+			search:      at(22, 11, 0),
+			want:        []time.Time{at(22, 10, 30)},
+			wantWindows: []string{"2020-04-22/2020-04-22"},
+		},
+		{
+			name:        "at or before across good friday",
+			search:      at(13, 8, 0),
+			options:     SearchOptions{Mode: SearchAtOrBefore},
+			want:        []time.Time{at(9, 15, 30)},
+			wantWindows: []string{"2020-04-13/2020-04-13", "2020-04-09/2020-04-09"},
+		},
+		{
+			name:        "at or after across good friday",
+			search:      at(9, 17, 0),
+			options:     SearchOptions{Mode: SearchAtOrAfter},
+			want:        []time.Time{at(13, 9, 30)},
+			wantWindows: []string{"2020-04-09/2020-04-09", "2020-04-13/2020-04-13"},
+		},
+		{
+			name:        "at or after a bar start",
+			search:      at(9, 10, 30),
+			options:     SearchOptions{Mode: SearchAtOrAfter},
+			want:        []time.Time{at(9, 10, 30)},
+			wantWindows: []string{"2020-04-09/2020-04-09"},
+		},
+		{
+			name:        "bracketing a bar start",
+			search:      at(9, 10, 30),
+			options:     SearchOptions{Mode: SearchBracketing},
+			want:        []time.Time{at(9, 10, 30), at(9, 11, 30)},
+			wantWindows: []string{"2020-04-09/2020-04-09"},
+		},
+		{
+			name:        "containing finds nothing after the close",
+			search:      at(9, 17, 0),
+			options:     SearchOptions{Mode: SearchContaining},
+			wantWindows: []string{"2020-04-09/2020-04-09"},
+			wantErr:     SearchReturnedNoResults,
+		},
+		{
+			name:        "lookback exceeded",
+			search:      at(1, 8, 0),
+			options:     SearchOptions{Mode: SearchAtOrBefore, Lookback: 3 * 24 * time.Hour},
+			wantWindows: []string{"2020-04-01/2020-04-01", "2020-03-31/2020-03-31", "2020-03-30/2020-03-30"},
+			wantErr:     LimitExceededError,
+		},
+		{
+			name:        "nothing after today",
+			search:      at(30, 17, 0),
+			options:     SearchOptions{Mode: SearchAtOrAfter},
+			wantWindows: []string{"2020-04-30/2020-04-30"},
+			wantErr:     SearchReturnedNoResults,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := []string{}
+			server := barsServer(bars, &windows)
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			pc := PolygonioClient{
+				HTTPClient: server.Client(),
+				BaseHost:   u.Host,
+				BaseScheme: u.Scheme,
+				Calendar:   calendar,
+				Clock:      &fakeClock{now: at(30, 18, 0)},
+			}
+			request := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: Hour}
+
+			got, err := pc.AggregatesSearchWith(context.Background(), request, tt.search, tt.options)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(windows, " ") != strings.Join(tt.wantWindows, " ") {
+				t.Errorf("windows = %v, want %v", windows, tt.wantWindows)
+			}
+			if err != nil {
+				se := &SearchError{}
+				if !errors.As(err, &se) || len(se.Windows) != len(tt.wantWindows) {
+					t.Errorf("err = %#v, want a *SearchError with the windows tried", err)
+				}
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].UnixMiliSecInTime().Equal(tt.want[i]) {
+					t.Errorf("bar %v = %v, want %v", i, got[i].UnixMiliSecInTime(), tt.want[i])
+				}
+			}
+		})
+	}
+}
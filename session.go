@@ -0,0 +1,78 @@
+package polygonio
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/maerlyn5/polygonio/calendar"
+)
+
+//Session is the session the bar starts in, in AmericaNewYork. Bars are tagged by their start so an hour
+//bar from 9:00 is pre-market even though it runs into the open, only minute bars split cleanly
+func (ar AggregatesResponse) Session() calendar.Session {
+	start := ar.UnixMiliSecInTime()
+	b, ok := calendar.SessionBounds(start)
+	if !ok {
+		return calendar.Closed
+	}
+	return b.Session(start)
+}
+
+//BySession keeps the bars starting in one of sessions, the envelope is copied and ResultsCount recounted
+func (arc AggregatesResponseContainer) BySession(sessions ...calendar.Session) AggregatesResponseContainer {
+	keep := map[calendar.Session]bool{}
+	for _, s := range sessions {
+		keep[s] = true
+	}
+	out := arc
+	out.Results = []AggregatesResponse{}
+	for _, bar := range arc.Results {
+		if keep[bar.Session()] {
+			out.Results = append(out.Results, bar)
+		}
+	}
+	out.ResultsCount = int64(len(out.Results))
+	return out
+}
+
+//RegularHoursOnly keeps the bars starting between the open and the close, 1pm on half days
+func (arc AggregatesResponseContainer) RegularHoursOnly() AggregatesResponseContainer {
+	return arc.BySession(calendar.Regular)
+}
+
+//ExtendedHours keeps the pre-market and after-hours bars, without the regular session
+func (arc AggregatesResponseContainer) ExtendedHours() AggregatesResponseContainer {
+	return arc.BySession(calendar.PreMarket, calendar.AfterHours)
+}
+
+//ErrBarsTooCoarse is returned by RegularDaily for bars that run over the open or the close, hour bars start at
+//9:00 and day bars at midnight. Bars of 30 minutes or a divisor of it line up with both
+var ErrBarsTooCoarse = errors.New("bars do not line up with the regular session")
+
+//RegularDaily rebuilds daily bars from the regular hours intraday bars, one per trading day starting at
+//midnight like polygon's own day bars. Unlike those it leaves out pre-market and after-hours trading.
+//Every bar has to end where ApplyRequest says and lie entirely inside or outside the regular session
+func (arc AggregatesResponseContainer) RegularDaily() ([]AggregatesResponse, error) {
+	for _, bar := range arc.Results {
+		start, end := bar.UnixMiliSecInTime(), bar.ImpliedEnd()
+		if !end.After(start) {
+			return nil, fmt.Errorf("%w: bar at %s has no width, ApplyRequest was not called", ErrBarsTooCoarse, start.Format(StringFormat))
+		}
+		b, ok := calendar.SessionBounds(start)
+		if !ok {
+			continue
+		}
+		//overlapping without being inside
+		if start.Before(b.Close) && end.After(b.Open) && (start.Before(b.Open) || end.After(b.Close)) {
+			return nil, fmt.Errorf("%w: %s-%s runs over %s-%s", ErrBarsTooCoarse, start.Format(StringFormat), end.Format(StringFormat), b.Open.Format(StringFormat), b.Close.Format(StringFormat))
+		}
+	}
+
+	out := Resample(arc.RegularHoursOnly().Results, 0, AnchorMidnight)
+	for i := range out {
+		out[i].timespan = Day
+		out[i].multiplier = 1
+		out[i].timespanDuration = Day.Duration()
+	}
+	return out, nil
+}
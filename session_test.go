@@ -0,0 +1,145 @@
+package polygonio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maerlyn5/polygonio/calendar"
+	"github.com/shopspring/decimal"
+)
+
+func TestAggregatesResponse_Session(t *testing.T) {
+
+	bar := func(t time.Time) AggregatesResponse {
+		return AggregatesResponse{UnixMiliSec: t.UnixNano() / int64(time.Millisecond)}
+	}
+	ny := func(month time.Month, day int, hour int, min int) time.Time {
+		return time.Date(2020, month, day, hour, min, 0, 0, AmericaNewYork)
+	}
+	tests := []struct {
+		name  string
+		start time.Time
+		want  calendar.Session
+	}{
+		{name: "overnight", start: ny(4, 24, 3, 59), want: calendar.Closed},
+		{name: "pre-market", start: ny(4, 24, 4, 0), want: calendar.PreMarket},
+		{name: "hour bar into the open", start: ny(4, 24, 9, 0), want: calendar.PreMarket},
+		{name: "open", start: ny(4, 24, 9, 30), want: calendar.Regular},
+		{name: "close", start: ny(4, 24, 16, 0), want: calendar.AfterHours},
+		{name: "late", start: ny(4, 24, 20, 0), want: calendar.Closed},
+		{name: "half day afternoon", start: ny(11, 27, 14, 0), want: calendar.AfterHours},
+		{name: "holiday", start: ny(4, 10, 10, 0), want: calendar.Closed},
+		{name: "open in utc after spring forward", start: time.Date(2020, 3, 9, 13, 30, 0, 0, time.UTC), want: calendar.Regular},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bar(tt.start).Session(); got != tt.want {
+				t.Errorf("Session() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatesResponseContainer_RegularDaily(t *testing.T) {
+
+	ny := func(day int, hour int, min int) time.Time {
+		return time.Date(2020, 11, day, hour, min, 0, 0, AmericaNewYork)
+	}
+	container := AggregatesResponseContainer{Ticker: "AAPL", Status: "OK"}
+	//30 minute bars from 4am to 8pm on the 25th and on the 27th, the day after thanksgiving closes at 1pm
+	for _, day := range []int{25, 27} {
+		for start := ny(day, 4, 0); start.Before(ny(day, 20, 0)); start = start.Add(30 * time.Minute) {
+			price := decimal.NewFromInt(int64(start.Hour()))
+			container.Results = append(container.Results, AggregatesResponse{
+				UnixMiliSec: start.UnixNano() / int64(time.Millisecond),
+				Open:        price,
+				Close:       price,
+				High:        price,
+				Low:         price,
+				VWAP:        price,
+				Volume:      decimal.NewFromInt(1),
+			})
+		}
+	}
+	container.ApplyRequest(AggregatesRequest{Multiplier: 30, Timespan: Minute})
+	container.ResultsCount = int64(len(container.Results))
+
+	regular := container.RegularHoursOnly()
+	//13 bars on the 25th, 7 on the 27th
+	if len(regular.Results) != 20 || regular.ResultsCount != 20 || regular.Ticker != "AAPL" {
+		t.Errorf("RegularHoursOnly() = %v bars, ResultsCount %v, ticker %v", len(regular.Results), regular.ResultsCount, regular.Ticker)
+	}
+	//after-hours end at 5pm on the 27th, the 6 bars after that are closed
+	if extended := container.ExtendedHours(); len(extended.Results) != len(container.Results)-20-6 {
+		t.Errorf("ExtendedHours() = %v bars, want %v", len(extended.Results), len(container.Results)-20-6)
+	}
+	if closed := container.BySession(calendar.Closed); len(closed.Results) != 6 {
+		t.Errorf("BySession(Closed) = %v bars, want 6", len(closed.Results))
+	}
+
+	daily, err := container.RegularDaily()
+	if err != nil || len(daily) != 2 {
+		t.Fatalf("RegularDaily() = %v, %v", daily, err)
+	}
+	tests := []struct {
+		bar       AggregatesResponse
+		wantStart time.Time
+		wantOpen  int64
+		wantClose int64
+		wantHigh  int64
+		wantVol   int64
+	}{
+		{bar: daily[0], wantStart: ny(25, 0, 0), wantOpen: 9, wantClose: 15, wantHigh: 15, wantVol: 13},
+		{bar: daily[1], wantStart: ny(27, 0, 0), wantOpen: 9, wantClose: 12, wantHigh: 12, wantVol: 7},
+	}
+	for _, tt := range tests {
+		if !tt.bar.UnixMiliSecInTime().Equal(tt.wantStart) || !tt.bar.ImpliedEnd().Equal(tt.wantStart.AddDate(0, 0, 1)) {
+			t.Errorf("bar %v-%v, want the day of %v", tt.bar.UnixMiliSecInTime(), tt.bar.ImpliedEnd(), tt.wantStart)
+		}
+		if tt.bar.Open.IntPart() != tt.wantOpen || tt.bar.Close.IntPart() != tt.wantClose || tt.bar.High.IntPart() != tt.wantHigh || tt.bar.Volume.IntPart() != tt.wantVol {
+			t.Errorf("bar = %v, want o:%v c:%v h:%v v:%v", tt.bar, tt.wantOpen, tt.wantClose, tt.wantHigh, tt.wantVol)
+		}
+	}
+}
+
+func TestAggregatesResponseContainer_RegularDailyTooCoarse(t *testing.T) {
+
+	//a monday, the open is 9:30
+	day := time.Date(2020, 4, 27, 0, 0, 0, 0, AmericaNewYork)
+	bars := func(request AggregatesRequest, from time.Time, to time.Time) AggregatesResponseContainer {
+		container := AggregatesResponseContainer{}
+		for start := from; start.Before(to); start = request.Timespan.Add(start, request.Multiplier) {
+			container.Results = append(container.Results, AggregatesResponse{UnixMiliSec: start.UnixNano() / int64(time.Millisecond), Volume: decimal.NewFromInt(1)})
+		}
+		container.ApplyRequest(request)
+		return container
+	}
+	tests := []struct {
+		name      string
+		container AggregatesResponseContainer
+		wantErr   bool
+	}{
+		{name: "minute", container: bars(AggregatesRequest{Multiplier: 1, Timespan: Minute}, day.Add(4*time.Hour), day.Add(20*time.Hour))},
+		{name: "15 minutes", container: bars(AggregatesRequest{Multiplier: 15, Timespan: Minute}, day.Add(4*time.Hour), day.Add(20*time.Hour))},
+		{name: "hour from 9:00 loses the first half hour", container: bars(AggregatesRequest{Multiplier: 1, Timespan: Hour}, day.Add(4*time.Hour), day.Add(20*time.Hour)), wantErr: true},
+		{name: "45 minutes", container: bars(AggregatesRequest{Multiplier: 45, Timespan: Minute}, day.Add(4*time.Hour), day.Add(20*time.Hour)), wantErr: true},
+		{name: "day bars", container: bars(AggregatesRequest{Multiplier: 1, Timespan: Day}, day, day.AddDate(0, 0, 5)), wantErr: true},
+		{name: "without ApplyRequest", container: AggregatesResponseContainer{Results: []AggregatesResponse{{UnixMiliSec: day.Add(10*time.Hour).UnixNano() / int64(time.Millisecond)}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daily, err := tt.container.RegularDaily()
+			if tt.wantErr {
+				if !errors.Is(err, ErrBarsTooCoarse) || daily != nil {
+					t.Errorf("RegularDaily() = %v, %v, want ErrBarsTooCoarse", daily, err)
+				}
+				return
+			}
+			//6.5 hours of regular trading
+			if err != nil || len(daily) != 1 || daily[0].Volume.IntPart() != int64(390*time.Minute/tt.container.Results[0].timespanDuration) {
+				t.Errorf("RegularDaily() = %v, %v", daily, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package polygonio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//ErrUnknownTimespan is returned for a request whose Timespan is not one of Timespans
+var ErrUnknownTimespan = errors.New("unknown timespan")
+
+//Timespan is the unit of an aggregates bar, day and longer are calendar units in AmericaNewYork
+type Timespan string
+
+const (
+	Minute  Timespan = "minute"
+	Hour    Timespan = "hour"
+	Day     Timespan = "day"
+	Week    Timespan = "week"
+	Month   Timespan = "month"
+	Quarter Timespan = "quarter"
+	Year    Timespan = "year"
+)
+
+var Timespans = []Timespan{Minute, Hour, Day, Week, Month, Quarter, Year}
+
+func (ts Timespan) Valid() bool {
+	for _, valid := range Timespans {
+		if ts == valid {
+			return true
+		}
+	}
+	return false
+}
+
+//validate is the error for requests made with ts, Add and Duration panic on an unknown timespan
+func (ts Timespan) validate() error {
+	if !ts.Valid() {
+		return fmt.Errorf("%w %q", ErrUnknownTimespan, string(ts))
+	}
+	return nil
+}
+
+func ParseTimespan(in string) (Timespan, error) {
+	if err := Timespan(in).validate(); err != nil {
+		return "", err
+	}
+	return Timespan(in), nil
+}
+
+//Add is the end of n timespans from start. Days and longer follow the calendar so a day
+//can be 23 or 25 hours and a month 28 to 31 days
+func (ts Timespan) Add(start time.Time, n int64) time.Time {
+	switch ts {
+	case Minute:
+		return start.Add(time.Duration(n) * time.Minute)
+	case Hour:
+		return start.Add(time.Duration(n) * time.Hour)
+	}
+
+	ny := start.In(AmericaNewYork)
+	switch ts {
+	case Day:
+		return ny.AddDate(0, 0, int(n))
+	case Week:
+		return ny.AddDate(0, 0, 7*int(n))
+	case Month:
+		return ny.AddDate(0, int(n), 0)
+	case Quarter:
+		return ny.AddDate(0, 3*int(n), 0)
+	case Year:
+		return ny.AddDate(int(n), 0, 0)
+	}
+	panic("unknown timespan " + string(ts))
+}
+
+//Duration is exact for minute and hour and an average for the calendar units, use Add for bar ends
+func (ts Timespan) Duration() time.Duration {
+	switch ts {
+	case Minute:
+		return time.Minute
+	case Hour:
+		return time.Hour
+	case Day:
+		return 24 * time.Hour
+	case Week:
+		return 7 * 24 * time.Hour
+	case Month: //aproximate
+		return time.Hour * 730
+	case Quarter:
+		return 3 * time.Hour * 730
+	case Year:
+		return 12 * time.Hour * 730
+	}
+	panic("unknown timespan " + string(ts))
+}
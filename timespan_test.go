@@ -0,0 +1,130 @@
+package polygonio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTimespan_Add(t *testing.T) {
+
+	ny := func(year int, month time.Month, day int, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, AmericaNewYork)
+	}
+	tests := []struct {
+		name      string
+		timespan  Timespan
+		start     time.Time
+		n         int64
+		want      time.Time
+		wantHours float64
+	}{
+		{name: "hour across spring forward", timespan: Hour, start: ny(2020, 3, 8, 1), n: 1, want: ny(2020, 3, 8, 3), wantHours: 1},
+		{name: "minutes", timespan: Minute, start: ny(2020, 3, 8, 1), n: 90, want: ny(2020, 3, 8, 3).Add(30 * time.Minute), wantHours: 1.5},
+		{name: "day of spring forward", timespan: Day, start: ny(2020, 3, 8, 0), n: 1, want: ny(2020, 3, 9, 0), wantHours: 23},
+		{name: "day of fall back", timespan: Day, start: ny(2020, 11, 1, 0), n: 1, want: ny(2020, 11, 2, 0), wantHours: 25},
+		{name: "week across dst", timespan: Week, start: ny(2020, 3, 2, 0), n: 2, want: ny(2020, 3, 16, 0), wantHours: 14*24 - 1},
+		{name: "leap february", timespan: Month, start: ny(2020, 2, 1, 0), n: 1, want: ny(2020, 3, 1, 0), wantHours: 29 * 24},
+		{name: "short february", timespan: Month, start: ny(2021, 2, 1, 0), n: 1, want: ny(2021, 3, 1, 0), wantHours: 28 * 24},
+		{name: "march loses an hour", timespan: Month, start: ny(2021, 3, 1, 0), n: 1, want: ny(2021, 4, 1, 0), wantHours: 31*24 - 1},
+		{name: "quarter gains an hour", timespan: Quarter, start: ny(2020, 10, 1, 0), n: 1, want: ny(2021, 1, 1, 0), wantHours: 92*24 + 1},
+		{name: "leap year", timespan: Year, start: ny(2020, 1, 1, 0), n: 1, want: ny(2021, 1, 1, 0), wantHours: 366 * 24},
+		{name: "start in utc", timespan: Day, start: ny(2020, 3, 8, 0).UTC(), n: 1, want: ny(2020, 3, 9, 0), wantHours: 23},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.timespan.Add(tt.start, tt.n)
+			if !got.Equal(tt.want) {
+				t.Errorf("Add() = %v, want %v", got, tt.want)
+			}
+			if hours := got.Sub(tt.start).Hours(); hours != tt.wantHours {
+				t.Errorf("Add() is %v hours later, want %v", hours, tt.wantHours)
+			}
+		})
+	}
+}
+
+func TestAggregatesResponse_CalendarImpliedEnd(t *testing.T) {
+
+	ny := func(month time.Month, day int, hour int) time.Time {
+		return time.Date(2020, month, day, hour, 0, 0, 0, AmericaNewYork)
+	}
+	container := AggregatesResponseContainer{}
+	for _, month := range []time.Month{1, 2, 3} {
+		container.Results = append(container.Results, AggregatesResponse{UnixMiliSec: ny(month, 1, 0).UnixNano() / int64(time.Millisecond)})
+	}
+	container.ApplyRequest(AggregatesRequest{Multiplier: 1, Timespan: Month})
+
+	tests := []struct {
+		name   string
+		search time.Time
+		want   time.Month
+	}{
+		{name: "last hour of a leap february", search: ny(2, 29, 23), want: 2},
+		{name: "first of march", search: ny(3, 1, 0), want: 3},
+		{name: "end of january", search: ny(1, 31, 23), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closest, found := container.ClosestAggregate(tt.search)
+			if !found || len(closest) != 1 {
+				t.Fatalf("ClosestAggregate() = %v, %v", closest, found)
+			}
+			if got := closest[0].UnixMiliSecInTime().In(AmericaNewYork).Month(); got != tt.want {
+				t.Errorf("ClosestAggregate() in %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if end := container.Results[1].ImpliedEnd(); !end.Equal(ny(3, 1, 0)) {
+		t.Errorf("ImpliedEnd() of february = %v", end)
+	}
+}
+
+func TestParseTimespan(t *testing.T) {
+	for _, ts := range Timespans {
+		if got, err := ParseTimespan(string(ts)); err != nil || got != ts {
+			t.Errorf("ParseTimespan(%v) = %v, %v", ts, got, err)
+		}
+		//used to panic on week and quarter
+		if TimespanAsDuration(string(ts)) <= 0 {
+			t.Errorf("TimespanAsDuration(%v) <= 0", ts)
+		}
+	}
+	if _, err := ParseTimespan("fortnight"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestPolygonioClient_UnknownTimespan(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"results":[]}`))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	pc := PolygonioClient{HTTPClient: server.Client(), BaseHost: u.Host, BaseScheme: u.Scheme}
+	day := time.Date(2020, 4, 24, 0, 0, 0, 0, AmericaNewYork)
+	request := AggregatesRequest{Ticker: "AAPL", Multiplier: 1, Timespan: "fortnight", From: day, To: day}
+	ctx := context.Background()
+
+	if _, err := pc.Aggregates(ctx, request); !errors.Is(err, ErrUnknownTimespan) {
+		t.Errorf("Aggregates() err = %v, want ErrUnknownTimespan", err)
+	}
+	if _, err := pc.AggregatesRange(ctx, request); !errors.Is(err, ErrUnknownTimespan) {
+		t.Errorf("AggregatesRange() err = %v, want ErrUnknownTimespan", err)
+	}
+	if _, err := pc.AggregatesSearchWith(ctx, request, day.Add(10*time.Hour), SearchOptions{}); !errors.Is(err, ErrUnknownTimespan) {
+		t.Errorf("AggregatesSearchWith() err = %v, want ErrUnknownTimespan", err)
+	}
+	if calls != 0 {
+		t.Errorf("calls = %v, want 0", calls)
+	}
+}
@@ -1,6 +1,10 @@
 package polygonio
 
-import "time"
+import (
+	"time"
+
+	"github.com/maerlyn5/polygonio/calendar"
+)
 
 var AmericaNewYork *time.Location = nil
 
@@ -16,30 +20,26 @@ func init() {
 
 }
 
+//FromDate is midnight of the trading day whose session t belongs to, the last one whose pre-market opened at
+//or before t
 func FromDate(t time.Time) time.Time {
-	t = t.In(AmericaNewYork)
-	if t.Hour() < 1 {
-		return t.AddDate(0, 0, -1)
+	b, err := calendar.SessionOf(t)
+	if err != nil {
+		return calendar.Day(t)
 	}
-
-	return t
+	return b.Date
 }
 
+//ToDate is midnight of the next trading day after the session t belongs to
 func ToDate(t time.Time) time.Time {
-	t = t.In(AmericaNewYork)
-	return t.AddDate(0, 0, 1)
+	b, err := calendar.NextSession(FromDate(t))
+	if err != nil {
+		return calendar.Day(t).AddDate(0, 0, 1)
+	}
+	return b.Date
 }
 
+//TimespanAsDuration panics on unknown timespans, see Timespan.Duration
 func TimespanAsDuration(in string) time.Duration {
-	switch in {
-	case "minute":
-		return time.Minute
-	case "hour":
-		return time.Hour
-	case "day": //aproximate
-		return time.Hour * 24
-	case "month": //aproximate
-		return time.Hour * 730
-	}
-	panic("unknown timespan")
+	return Timespan(in).Duration()
 }